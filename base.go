@@ -0,0 +1,184 @@
+package main
+
+import (
+	"github.com/go-openapi/spec"
+	"github.com/jackmanlabs/errors"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// The members of these top-level objects are merged individually, to the given
+// depth. Anything deeper, e.g. an operation or a definition, is taken as a
+// whole from one document or the other; mixing the two would produce
+// something nobody wrote. Other top-level members are taken as a whole.
+var baseMergeDepths map[string]int = map[string]int{
+	"info":                1, // title, description, contact, etc.
+	"paths":               2, // path, method
+	"definitions":         1,
+	"parameters":          1,
+	"responses":           1,
+	"securityDefinitions": 1,
+}
+
+// The base document is a hand-written Swagger 2.0 document, as JSON or YAML.
+func loadBase(path string) (map[string]interface{}, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	base, err := parseRawValue(string(b))
+	if err != nil {
+		return nil, errors.Newf("%s: %s", path, err)
+	}
+
+	if version, _ := base["swagger"].(string); version != "2.0" {
+		return nil, errors.Newf("%s: Only Swagger 2.0 documents are supported as a base.", path)
+	}
+
+	return base, nil
+}
+
+// This merges the generated document into the base document. When both define
+// the same member differently, the conflict is reported and the version of the
+// preferred document is used.
+func mergeBase(swagger *spec.Swagger, base map[string]interface{}, preferBase bool) error {
+
+	generated, err := marshalDocument(swagger)
+	if err != nil {
+		return errors.Stack(err)
+	}
+
+	conflicts := 0
+	report := func(pointer string) {
+		conflicts++
+
+		winner := "generated"
+		if preferBase {
+			winner = "base"
+		}
+
+		log.Printf("WARNING: Conflict between the base and generated documents at %s; the %s version is used.", pointer, winner)
+	}
+
+	for _, key := range sortedKeys(base) {
+		baseValue := base[key]
+		generatedValue, ok := generated[key]
+
+		if !ok {
+			generated[key] = baseValue
+		} else if key == "tags" {
+			generated[key] = mergeBaseTags(baseValue, generatedValue, preferBase, report)
+		} else {
+			generated[key] = mergeBaseMember("/"+key, baseValue, generatedValue, baseMergeDepths[key], preferBase, report)
+		}
+	}
+
+	if conflicts > 0 {
+		log.Printf("WARNING: %d conflicts between the base and generated documents.", conflicts)
+	}
+
+	err = unmarshalDocument(generated, swagger)
+	if err != nil {
+		return errors.Newf("The base document doesn't fit: %s", err)
+	}
+
+	return nil
+}
+
+func mergeBaseMember(pointer string, base, generated interface{}, depth int, preferBase bool, report func(string)) interface{} {
+
+	if reflect.DeepEqual(base, generated) {
+		return generated
+	}
+
+	baseMap, baseOk := base.(map[string]interface{})
+	generatedMap, generatedOk := generated.(map[string]interface{})
+
+	if depth > 0 && baseOk && generatedOk {
+		for _, key := range sortedKeys(baseMap) {
+			generatedValue, ok := generatedMap[key]
+			if !ok {
+				generatedMap[key] = baseMap[key]
+				continue
+			}
+
+			generatedMap[key] = mergeBaseMember(pointer+"/"+escapePointer(key), baseMap[key], generatedValue, depth-1, preferBase, report)
+		}
+
+		return generatedMap
+	}
+
+	report(pointer)
+
+	if preferBase {
+		return base
+	}
+
+	return generated
+}
+
+// Tags are identified by name. The tags of the base document keep their order,
+// and new tags are appended in the order they were generated.
+func mergeBaseTags(base, generated interface{}, preferBase bool, report func(string)) interface{} {
+
+	baseTags, _ := base.([]interface{})
+	generatedTags, _ := generated.([]interface{})
+
+	tagName := func(tag interface{}) string {
+		tagMap, _ := tag.(map[string]interface{})
+		name, _ := tagMap["name"].(string)
+		return name
+	}
+
+	generatedIndex := make(map[string]interface{})
+	for _, tag := range generatedTags {
+		generatedIndex[tagName(tag)] = tag
+	}
+
+	merged := make([]interface{}, 0, len(baseTags)+len(generatedTags))
+	baseNames := make(map[string]bool)
+
+	for _, tag := range baseTags {
+		name := tagName(tag)
+		baseNames[name] = true
+
+		generatedTag, ok := generatedIndex[name]
+		if !ok {
+			merged = append(merged, tag)
+			continue
+		}
+
+		merged = append(merged, mergeBaseMember("/tags/"+escapePointer(name), tag, generatedTag, 0, preferBase, report))
+	}
+
+	for _, tag := range generatedTags {
+		if !baseNames[tagName(tag)] {
+			merged = append(merged, tag)
+		}
+	}
+
+	return merged
+}
+
+// JSON pointers (RFC 6901) escape '~' and '/' in member names.
+func escapePointer(name string) string {
+	name = strings.Replace(name, "~", "~0", -1)
+	name = strings.Replace(name, "/", "~1", -1)
+	return name
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -30,15 +30,13 @@ func (this DefinitionStore) Add(intermediates ...*DefinitionIntermediate) {
 
 func (this DefinitionStore) ExistsDefinition(referringPackage, typeName string) (*DefinitionIntermediate, bool) {
 
-	typeName = strings.TrimPrefix(typeName, "*")
+	typeName = strings.TrimLeft(typeName, "*")
+	typeName = absoluteTypeArgs(referringPackage, typeName)
 
 	pkgInfo := pkgInfos[referringPackage]
 	importPaths := possibleImportPaths(pkgInfo, typeName)
 
-	idx := strings.Index(typeName, ".")
-	if idx != -1 {
-		typeName = typeName[idx+1:]
-	}
+	_, typeName = splitTypeName(typeName)
 
 	for _, importPath := range importPaths {
 		for _, def := range this {
@@ -2,7 +2,9 @@ package main
 
 import (
 	"github.com/jackmanlabs/errors"
+	"log"
 	"strings"
+	"unicode"
 )
 
 func deriveDefinitionsFromOperations(operationIntermediates []OperationIntermediate) (DefinitionStore, error) {
@@ -29,19 +31,25 @@ func deriveDefinitionsFromOperations(operationIntermediates []OperationIntermedi
 		}
 
 		for _, parameterIntermediate := range operationIntermediate.Parameters {
-			var typ SchemerDefiner = parameterIntermediate.Type
-			referringPackage := operationIntermediate.PackagePath
-			goType := typ.GoType()
-
-			defs, err := getDefinition(defStore, referringPackage, goType)
-			if err != nil {
-				return defStore, errors.Stack(err)
+			var types []SchemerDefiner = []SchemerDefiner{parameterIntermediate.Type}
+			for _, mediaType := range parameterIntermediate.MediaTypes {
+				types = append(types, mediaType.Type)
 			}
 
-			if len(defs) > 0 {
-				typ.SetPackageName(defs[0].PackageName)
-				typ.SetPackagePath(defs[0].PackagePath)
-				defStore.Add(defs...)
+			for _, typ := range types {
+				referringPackage := operationIntermediate.PackagePath
+				goType := typ.GoType()
+
+				defs, err := getDefinition(defStore, referringPackage, goType)
+				if err != nil {
+					return defStore, errors.Stack(err)
+				}
+
+				if len(defs) > 0 {
+					typ.SetPackageName(defs[0].PackageName)
+					typ.SetPackagePath(defs[0].PackagePath)
+					defStore.Add(defs...)
+				}
 			}
 		}
 	}
@@ -64,9 +72,88 @@ func deriveDefinitionsFromOperations(operationIntermediates []OperationIntermedi
 		}
 	}
 
+	resolvePackages(defStore, operationIntermediates)
+
 	return defStore, nil
 }
 
+// Members only know the Go types they refer to. Once all of the definitions are
+// known, this tells every member, including the elements of collections, which
+// package its type belongs to, so that it can be named.
+func resolvePackages(defStore DefinitionStore, operationIntermediates []OperationIntermediate) {
+
+	resolved := make(map[SchemerDefiner]bool)
+
+	for _, operationIntermediate := range operationIntermediates {
+		for _, responseIntermediate := range operationIntermediate.Responses {
+			resolvePackage(defStore, operationIntermediate.PackagePath, responseIntermediate.Type, resolved)
+		}
+
+		for _, parameterIntermediate := range operationIntermediate.Parameters {
+			resolvePackage(defStore, operationIntermediate.PackagePath, parameterIntermediate.Type, resolved)
+			for _, mediaType := range parameterIntermediate.MediaTypes {
+				resolvePackage(defStore, operationIntermediate.PackagePath, mediaType.Type, resolved)
+			}
+		}
+	}
+
+	for _, def := range defStore {
+		defs := append([]*DefinitionIntermediate{def}, def.InlineDefinitions()...)
+		for _, def_ := range defs {
+			for _, member := range def_.Members {
+				// Promoted members refer to types from the package of the
+				// embedded struct.
+				referringPackage := def_.PackagePath
+				if member.GetPackagePath() != "" {
+					referringPackage = member.GetPackagePath()
+				}
+
+				resolvePackage(defStore, referringPackage, member, resolved)
+			}
+		}
+	}
+}
+
+func resolvePackage(defStore DefinitionStore, referringPackage string, typ SchemerDefiner, resolved map[SchemerDefiner]bool) {
+
+	if typ == nil || resolved[typ] {
+		return
+	}
+	resolved[typ] = true
+
+	switch t := typ.(type) {
+	case *SliceIntermediate:
+		resolvePackage(defStore, referringPackage, t.ValueType, resolved)
+	case *MapIntermediate:
+		resolvePackage(defStore, referringPackage, t.KeyType, resolved)
+		resolvePackage(defStore, referringPackage, t.ValueType, resolved)
+
+		keySchema, ok := describeMapKey(defStore, referringPackage, t.KeyType.Type)
+		if !ok {
+			log.Printf("ERROR: The keys of map '%s' (%s) can't be marshalled by encoding/json.", t.Name, t.Type)
+		}
+		t.KeySchema = keySchema
+	case *MemberIntermediate:
+		if t.Inline != nil {
+			return
+		}
+
+		if schema, ok := mappedSchema(referringPackage, t.Type); ok {
+			t.Mapping = schema
+			return
+		}
+
+		if isPrimitive, _, _ := IsPrimitive(t.Type); isPrimitive {
+			return
+		}
+
+		if def, ok := defStore.ExistsDefinition(referringPackage, t.Type); ok {
+			t.PackagePath = def.PackagePath
+			t.PackageName = def.PackageName
+		}
+	}
+}
+
 // This is used to allow incremental building of the definition store.
 // Otherwise, we risk a lot of duplicate lookups.
 func findNextUnknownDefinition(defStore DefinitionStore) ([]*DefinitionIntermediate, error) {
@@ -74,7 +161,23 @@ func findNextUnknownDefinition(defStore DefinitionStore) ([]*DefinitionIntermedi
 	idx := 0
 	for _, def := range defStore {
 
+		members := make([]SchemerDefiner, 0, len(def.Members))
 		for _, member := range def.Members {
+			members = append(members, member)
+		}
+
+		// The members of anonymous structs are found in the same package.
+		for _, inline := range def.InlineDefinitions() {
+			for _, member := range inline.AllMembers() {
+				members = append(members, member)
+			}
+		}
+
+		for _, member := range members {
+
+			if inlineDefinition(member) != nil {
+				continue
+			}
 
 			//log.Printf("#%d Examining member: %s.%s (%s)", idx, def.Name, memberName, member.GoType())
 
@@ -97,6 +200,10 @@ func findNextUnknownDefinition(defStore DefinitionStore) ([]*DefinitionIntermedi
 					referringPackage = member.GetPackagePath()
 				}
 
+				if _, ok := mappedSchema(referringPackage, goType); ok {
+					continue
+				}
+
 				newDefs, err := getDefinition(defStore, referringPackage, goType)
 				if err != nil {
 					return nil, errors.Stack(err)
@@ -118,18 +225,14 @@ func findNextUnknownDefinition(defStore DefinitionStore) ([]*DefinitionIntermedi
 
 func getComponentTypes(goType string) []string {
 
-	var types []string
-
-	// There was a real temptation to make this recursive instead of a loop.
+	// Collections may be nested, e.g. 'map[string][]Village'.
 	if ok, t := IsSlice(goType); ok {
-		types = []string{t}
+		return getComponentTypes(t)
 	} else if ok, t, u := IsMap(goType); ok {
-		types = []string{t, u}
-	} else {
-		types = []string{goType}
+		return append(getComponentTypes(t), getComponentTypes(u)...)
 	}
 
-	return types
+	return []string{goType}
 }
 
 // This is troublesome.
@@ -162,6 +265,11 @@ func getDefinition(defStore DefinitionStore, referringPackage, parentType string
 			continue
 		}
 
+		// Mapped types don't need definitions.
+		if _, ok := mappedSchema(referringPackage, goType); ok {
+			continue
+		}
+
 		def, ok := defStore.ExistsDefinition(referringPackage, goType)
 
 		if ok {
@@ -178,24 +286,69 @@ func getDefinition(defStore DefinitionStore, referringPackage, parentType string
 		// Embedded types require special treatment. we need the definitions
 		// right now to construct the flattened struct. Also, we don't
 		// necessarily want the embedded struct type to show up in the
-		// definitions.
-		// Suggestion for enhancement: get the embedded types first, possibly in
-		// a separate store.
-		for _, embeddedType := range def.EmbeddedTypes {
-			embeddedDef, ok := defStore.ExistsDefinition(def.PackagePath, embeddedType)
-			if !ok {
-				embeddedDef, err = findDefinition(def.PackagePath, embeddedType)
-				if err != nil {
-					return nil, errors.Stack(err)
-				} else if embeddedDef == nil {
-					return defs, errors.Newf("Failed to generate definition for embedded type '%s' of '%s' referenced in package '%s'", embeddedType, goType, def.PackagePath)
-				}
+		// definitions, unless they're composed with allOf.
+		bases, err := embedDefinitions(defStore, def, map[string]bool{})
+		if err != nil {
+			return defs, errors.Stack(err)
+		}
+
+		defs = append(defs, def)
+		defs = append(defs, bases...)
+
+		implementations, err := implementDefinitions(defStore, def)
+		if err != nil {
+			return defs, errors.Stack(err)
+		}
+
+		defs = append(defs, implementations...)
+	}
+
+	return defs, nil
+}
+
+// The implementations of an interface with a discriminator are defined along
+// with it, and are composed with it as their first base. This returns the
+// definitions that weren't known yet.
+func implementDefinitions(defStore DefinitionStore, def *DefinitionIntermediate) ([]*DefinitionIntermediate, error) {
+
+	defs := make([]*DefinitionIntermediate, 0)
+
+	if def.Discriminator == "" {
+		if len(def.Implementations) > 0 {
+			log.Printf("WARNING: The implementations of '%s' are ignored without a discriminator.", def.Name)
+		}
+		return defs, nil
+	}
+
+	if def.UnderlyingType != "interface{}" {
+		log.Printf("WARNING: Type '%s' has a discriminator, but isn't an interface.", def.Name)
+	}
+
+	for i, implementation := range def.Implementations {
+		implDef, ok := defStore.ExistsDefinition(def.PackagePath, implementation.Type)
+		if !ok {
+			newDefs, err := getDefinition(defStore, def.PackagePath, implementation.Type)
+			if err != nil {
+				return defs, errors.Stack(err)
+			} else if len(newDefs) == 0 {
+				return defs, errors.Newf("Failed to generate definition for implementation '%s' of '%s'", implementation.Type, def.Name)
 			}
 
-			mergeDefinitions(def, embeddedDef)
+			// The implementations may refer to each other through the
+			// interface, so they're known right away.
+			defStore.Add(newDefs...)
+			defs = append(defs, newDefs...)
+			implDef = newDefs[0]
 		}
 
-		defs = append(defs, def)
+		if implDef.UnderlyingType != "struct" || implDef.SchemaType != "" {
+			log.Printf("WARNING: Implementation '%s' of '%s' isn't described as an object, so it can't be composed with it.", implementation.Type, def.Name)
+			continue
+		}
+
+		implDef.Bases = append([]*DefinitionIntermediate{def}, implDef.Bases...)
+		implDef.DiscriminatorValue = implementation.Value
+		def.Implementations[i].Definition = implDef
 	}
 
 	return defs, nil
@@ -204,14 +357,11 @@ func getDefinition(defStore DefinitionStore, referringPackage, parentType string
 // What packages could have possibly contained this type?
 func possibleImportPaths(pkgInfo PackageInfo, goType string) []string {
 
-	if !strings.Contains(goType, ".") {
+	alias, _ := splitTypeName(strings.TrimLeft(goType, "*"))
+	if alias == "" {
 		return []string{pkgInfo.ImportPath}
 	}
 
-	chunks := strings.Split(goType, ".")
-
-	alias := chunks[0]
-
 	importPaths := make([]string, 0)
 
 	for importPath, aliases := range pkgInfo.Imports {
@@ -224,5 +374,353 @@ func possibleImportPaths(pkgInfo PackageInfo, goType string) []string {
 		}
 	}
 
+	// Type arguments are qualified by import paths; see absoluteType.
+	if len(importPaths) == 0 && isImportPath(pkgInfo, alias) {
+		importPaths = append(importPaths, alias)
+	}
+
 	return importPaths
 }
+
+// An import path without a slash (e.g. 'time') looks just like an alias, so
+// it's only taken as an import path if the package doesn't import a package
+// that could be named like it.
+func isImportPath(pkgInfo PackageInfo, qualifier string) bool {
+
+	if strings.Contains(qualifier, "/") {
+		return true
+	}
+
+	if _, ok := pkgInfos[qualifier]; !ok {
+		return false
+	}
+
+	for importPath := range pkgInfo.Imports {
+		if guessPackageName(importPath) == qualifier {
+			return importPath == qualifier
+		}
+	}
+
+	return true
+}
+
+// A field promoted from an embedded struct, competing for its JSON name.
+type promotedMember struct {
+	Name     string // The member name in its definition.
+	Member   SchemerDefiner
+	Depth    int
+	Tagged   bool
+	Optional bool
+	Source   *DefinitionIntermediate // The embedded definition; nil for the parent's own fields.
+}
+
+/*
+This resolves the embedded types of the definition as encoding/json does:
+
+  - The fields of an embedded struct are promoted to the parent.
+  - A field shadows the fields of the same JSON name that are embedded more
+    deeply. Among fields at the same depth, a tagged field wins; if there are
+    several, or none, they are all left out.
+  - The fields of an embedded pointer are left out when it's nil, so they're
+    never required.
+  - An embedded type that isn't a struct is a field named after its type.
+
+With '-embedding allOf', an embedded struct becomes a base of the definition,
+composed with allOf, as long as that describes the same JSON: it must not be a
+pointer, and none of its fields may be shadowed or left out. Otherwise, its
+fields are promoted.
+
+The definitions of the bases are returned so that they can be stored. The chain
+contains the canonical names of the definitions being resolved, to break
+cycles.
+*/
+func embedDefinitions(defStore DefinitionStore, def *DefinitionIntermediate, chain map[string]bool) ([]*DefinitionIntermediate, error) {
+
+	var (
+		bases     []*DefinitionIntermediate        = make([]*DefinitionIntermediate, 0)
+		embedded  []*DefinitionIntermediate        = make([]*DefinitionIntermediate, 0, len(def.EmbeddedTypes))
+		promoters []*DefinitionIntermediate        = make([]*DefinitionIntermediate, 0, len(def.EmbeddedTypes))
+		pointers  map[*DefinitionIntermediate]bool = make(map[*DefinitionIntermediate]bool)
+	)
+
+	chain[def.CanonicalName()] = true
+	defer delete(chain, def.CanonicalName())
+
+	// Anonymous structs may embed types too.
+	seen := make(map[*DefinitionIntermediate]bool)
+	for _, member := range def.Members {
+		inline := inlineDefinition(member)
+		if inline == nil || seen[inline] {
+			continue
+		}
+		seen[inline] = true
+
+		newBases, err := embedDefinitions(defStore, inline, chain)
+		if err != nil {
+			return nil, errors.Stack(err)
+		}
+
+		bases = append(bases, newBases...)
+	}
+
+	for _, embeddedType := range def.EmbeddedTypes {
+		embeddedDef, ok := defStore.ExistsDefinition(def.PackagePath, embeddedType)
+		if !ok {
+			var err error
+			embeddedDef, err = findDefinition(def.PackagePath, embeddedType)
+			if err != nil {
+				return nil, errors.Stack(err)
+			} else if embeddedDef == nil {
+				return nil, errors.Newf("Failed to generate definition for embedded type '%s' of '%s' referenced in package '%s'", embeddedType, def.Name, def.PackagePath)
+			}
+
+			if chain[embeddedDef.CanonicalName()] {
+				log.Printf("WARNING: Embedded type '%s' of '%s' is recursive and is left out.", embeddedType, def.Name)
+				continue
+			}
+
+			newBases, err := embedDefinitions(defStore, embeddedDef, chain)
+			if err != nil {
+				return nil, errors.Stack(err)
+			}
+
+			bases = append(bases, newBases...)
+		}
+
+		// Any embedded type may promote methods.
+		promoters = append(promoters, embeddedDef)
+		pointers[embeddedDef] = strings.HasPrefix(embeddedType, "*")
+
+		if embeddedDef.UnderlyingType != "struct" {
+			if unicode.IsLower([]rune(embeddedDef.Name)[0]) {
+				continue
+			}
+
+			def.Members[embeddedDef.Name] = &MemberIntermediate{
+				Name:        embeddedDef.Name,
+				Type:        embeddedType,
+				PackagePath: def.PackagePath,
+				Validations: make(ValidationMap),
+			}
+			continue
+		}
+
+		embedded = append(embedded, embeddedDef)
+	}
+
+	// A marshaler promoted from an embedded type marshals the whole value, e.g.
+	// 'struct{ time.Time }' is a string.
+	promoteMethods(def, promoters, pointers)
+	applyMarshalers(def)
+	if def.SchemaType != "" {
+		return make([]*DefinitionIntermediate, 0), nil
+	}
+
+	// Every field competes for its JSON name.
+	candidates := make(map[string][]promotedMember)
+	for name, member := range def.Members {
+		jsonName, tagged := memberJsonName(member)
+		candidates[jsonName] = append(candidates[jsonName], promotedMember{
+			Name:     name,
+			Member:   member,
+			Depth:    def.Depths[name],
+			Tagged:   tagged,
+			Optional: def.Optional[name],
+		})
+	}
+
+	for _, embeddedDef := range embedded {
+		for _, promoted := range visibleMembers(embeddedDef, 1) {
+			promoted.Source = embeddedDef
+			promoted.Optional = promoted.Optional || pointers[embeddedDef]
+
+			jsonName, _ := memberJsonName(promoted.Member)
+			candidates[jsonName] = append(candidates[jsonName], promoted)
+		}
+	}
+
+	// Only the dominant field of each JSON name survives.
+	dominant := make(map[string]promotedMember)
+	for jsonName, competitors := range candidates {
+		if winner, ok := dominantMember(competitors); ok {
+			dominant[jsonName] = winner
+		} else {
+			log.Printf("WARNING: The fields named '%s' of '%s' conflict and are left out, as encoding/json does.", jsonName, def.Name)
+		}
+	}
+
+	// The parent's own fields that lost are left out.
+	for name, member := range def.Members {
+		jsonName, _ := memberJsonName(member)
+		if winner, ok := dominant[jsonName]; !ok || winner.Source != nil || winner.Name != name {
+			delete(def.Members, name)
+		}
+	}
+
+	for _, embeddedDef := range embedded {
+
+		// A base must describe all of its fields.
+		composable := *embedding == "allOf" && !pointers[embeddedDef]
+		for _, promoted := range visibleMembers(embeddedDef, 1) {
+			jsonName, _ := memberJsonName(promoted.Member)
+			if winner, ok := dominant[jsonName]; !ok || winner.Source != embeddedDef {
+				composable = false
+			}
+		}
+
+		if composable {
+			def.Bases = append(def.Bases, embeddedDef)
+			bases = append(bases, embeddedDef)
+			continue
+		}
+
+		if *embedding == "allOf" {
+			log.Printf("WARNING: Embedded type '%s' of '%s' is a pointer or has shadowed fields, so its fields are promoted instead of composed.", embeddedDef.Name, def.Name)
+		}
+
+		for _, promoted := range dominant {
+			if promoted.Source != embeddedDef {
+				continue
+			}
+
+			// The members are described relative to the package in which they
+			// were declared.
+			if promoted.Member.GetPackagePath() == "" {
+				promoted.Member.SetPackagePath(embeddedDef.PackagePath)
+			}
+
+			name := promoted.Name
+			if _, exists := def.Members[name]; exists {
+				name = embeddedDef.Name + "." + name
+			}
+
+			def.Members[name] = promoted.Member
+			def.Depths[name] = promoted.Depth
+			def.Optional[name] = promoted.Optional
+		}
+	}
+
+	return bases, nil
+}
+
+// Methods are promoted by the rules of Go: the definition's own methods win,
+// then the shallowest promoted ones, unless several are equally shallow. The
+// methods of a type embedded by pointer are all in the method set of a value.
+func promoteMethods(def *DefinitionIntermediate, embedded []*DefinitionIntermediate, pointers map[*DefinitionIntermediate]bool) {
+
+	type promotedMethod struct {
+		Depth           int
+		PointerReceiver bool
+	}
+
+	candidates := make(map[string][]promotedMethod)
+	for _, embeddedDef := range embedded {
+		for name, pointerReceiver := range embeddedDef.Methods {
+			candidates[name] = append(candidates[name], promotedMethod{
+				Depth:           embeddedDef.MethodDepths[name] + 1,
+				PointerReceiver: pointerReceiver && !pointers[embeddedDef],
+			})
+		}
+	}
+
+	for name, competitors := range candidates {
+		if _, ok := def.Methods[name]; ok {
+			continue
+		}
+
+		winner, ambiguous := competitors[0], false
+		for _, competitor := range competitors[1:] {
+			if competitor.Depth < winner.Depth {
+				winner, ambiguous = competitor, false
+			} else if competitor.Depth == winner.Depth {
+				ambiguous = true
+			}
+		}
+
+		if ambiguous {
+			continue
+		}
+
+		def.Methods[name] = winner.PointerReceiver
+		def.MethodDepths[name] = winner.Depth
+	}
+}
+
+// The members visible through a definition are its own, and those of its bases,
+// one level deeper.
+func visibleMembers(def *DefinitionIntermediate, depth int) []promotedMember {
+
+	members := make([]promotedMember, 0, len(def.Members))
+
+	for name, member := range def.Members {
+		_, tagged := memberJsonName(member)
+		members = append(members, promotedMember{
+			Name:     name,
+			Member:   member,
+			Depth:    depth + def.Depths[name],
+			Tagged:   tagged,
+			Optional: def.Optional[name],
+		})
+	}
+
+	for _, base := range def.Bases {
+		members = append(members, visibleMembers(base, depth+1)...)
+	}
+
+	return members
+}
+
+func dominantMember(competitors []promotedMember) (promotedMember, bool) {
+
+	shallowest := make([]promotedMember, 0, len(competitors))
+	for _, competitor := range competitors {
+		if len(shallowest) > 0 && competitor.Depth > shallowest[0].Depth {
+			continue
+		}
+
+		if len(shallowest) > 0 && competitor.Depth < shallowest[0].Depth {
+			shallowest = shallowest[:0]
+		}
+
+		shallowest = append(shallowest, competitor)
+	}
+
+	if len(shallowest) == 1 {
+		return shallowest[0], true
+	}
+
+	tagged := make([]promotedMember, 0, len(shallowest))
+	for _, competitor := range shallowest {
+		if competitor.Tagged {
+			tagged = append(tagged, competitor)
+		}
+	}
+
+	if len(tagged) == 1 {
+		return tagged[0], true
+	}
+
+	return promotedMember{}, false
+}
+
+// This returns the name of the member in JSON, and whether it was given by a
+// tag.
+func memberJsonName(member SchemerDefiner) (string, bool) {
+
+	var name, jsonName string
+
+	switch t := member.(type) {
+	case *MemberIntermediate:
+		name, jsonName = t.Name, t.JsonName
+	case *SliceIntermediate:
+		name, jsonName = t.Name, t.JsonName
+	case *MapIntermediate:
+		name, jsonName = t.Name, t.JsonName
+	}
+
+	if jsonName != "" {
+		return jsonName, true
+	}
+
+	return name, false
+}
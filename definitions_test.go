@@ -0,0 +1,293 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const testPackagePath = "example.com/app/types"
+
+// A field records the type declaring it in its description, so that the
+// survivors of embedding can be told apart.
+type testField struct {
+	Name     string
+	JsonName string
+}
+
+type testStruct struct {
+	Name     string
+	Embedded []string
+	Fields   []testField
+}
+
+func newTestDefinition(s testStruct) *DefinitionIntermediate {
+
+	def := &DefinitionIntermediate{
+		Name:           s.Name,
+		PackageName:    "types",
+		PackagePath:    testPackagePath,
+		UnderlyingType: "struct",
+		Members:        make(map[string]SchemerDefiner),
+		EmbeddedTypes:  s.Embedded,
+		Depths:         make(map[string]int),
+		Optional:       make(map[string]bool),
+		Methods:        make(map[string]bool),
+		MethodDepths:   make(map[string]int),
+	}
+
+	for _, field := range s.Fields {
+		def.Members[field.Name] = &MemberIntermediate{
+			Name:        field.Name,
+			JsonName:    field.JsonName,
+			Type:        "string",
+			Description: s.Name,
+			Validations: make(ValidationMap),
+		}
+	}
+
+	return def
+}
+
+func TestEmbedDefinitions(t *testing.T) {
+
+	defer func(saved map[string]PackageInfo) { pkgInfos = saved }(pkgInfos)
+	defer func(saved string) { *embedding = saved }(*embedding)
+
+	pkgInfos = map[string]PackageInfo{
+		testPackagePath: {ImportPath: testPackagePath, PackageName: "types"},
+	}
+
+	tests := []struct {
+		name      string
+		embedding string
+		structs   []testStruct // Embedded types first; the last one is tested.
+		want      map[string]string
+		optional  []string
+		bases     []string
+	}{
+		{
+			name: "own fields win",
+			structs: []testStruct{
+				{Name: "Inner", Fields: []testField{{Name: "Name"}, {Name: "ID"}}},
+				{Name: "Outer", Embedded: []string{"Inner"}, Fields: []testField{{Name: "Name"}}},
+			},
+			want: map[string]string{"Name": "Outer", "ID": "Inner"},
+		},
+		{
+			name: "shallower fields win",
+			structs: []testStruct{
+				{Name: "Deep", Fields: []testField{{Name: "Name"}}},
+				{Name: "Middle", Embedded: []string{"Deep"}},
+				{Name: "Shallow", Fields: []testField{{Name: "Name"}}},
+				{Name: "Outer", Embedded: []string{"Middle", "Shallow"}},
+			},
+			want: map[string]string{"Name": "Shallow"},
+		},
+		{
+			name: "tagged fields win at the same depth",
+			structs: []testStruct{
+				{Name: "Left", Fields: []testField{{Name: "Name"}}},
+				{Name: "Right", Fields: []testField{{Name: "Label", JsonName: "Name"}}},
+				{Name: "Outer", Embedded: []string{"Left", "Right"}},
+			},
+			want: map[string]string{"Name": "Right"},
+		},
+		{
+			name: "conflicting fields are left out",
+			structs: []testStruct{
+				{Name: "Left", Fields: []testField{{Name: "Name"}, {Name: "Left"}}},
+				{Name: "Right", Fields: []testField{{Name: "Name"}, {Name: "Right"}}},
+				{Name: "Outer", Embedded: []string{"Left", "Right"}},
+			},
+			want: map[string]string{"Left": "Left", "Right": "Right"},
+		},
+		{
+			name: "conflicting tagged fields are left out",
+			structs: []testStruct{
+				{Name: "Left", Fields: []testField{{Name: "A", JsonName: "name"}}},
+				{Name: "Right", Fields: []testField{{Name: "B", JsonName: "name"}}},
+				{Name: "Outer", Embedded: []string{"Left", "Right"}},
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "fields of pointers are optional",
+			structs: []testStruct{
+				{Name: "Inner", Fields: []testField{{Name: "ID"}}},
+				{Name: "Outer", Embedded: []string{"*Inner"}, Fields: []testField{{Name: "Name"}}},
+			},
+			want:     map[string]string{"Name": "Outer", "ID": "Inner"},
+			optional: []string{"ID"},
+		},
+		{
+			name:      "embedded structs are composed",
+			embedding: "allOf",
+			structs: []testStruct{
+				{Name: "Inner", Fields: []testField{{Name: "ID"}}},
+				{Name: "Outer", Embedded: []string{"Inner"}, Fields: []testField{{Name: "Name"}}},
+			},
+			want:  map[string]string{"Name": "Outer"},
+			bases: []string{"Inner"},
+		},
+		{
+			name:      "shadowed structs are promoted instead of composed",
+			embedding: "allOf",
+			structs: []testStruct{
+				{Name: "Inner", Fields: []testField{{Name: "ID"}, {Name: "Name"}}},
+				{Name: "Outer", Embedded: []string{"Inner"}, Fields: []testField{{Name: "Name"}}},
+			},
+			want: map[string]string{"Name": "Outer", "ID": "Inner"},
+		},
+		{
+			name:      "structs embedded by pointer are promoted instead of composed",
+			embedding: "allOf",
+			structs: []testStruct{
+				{Name: "Inner", Fields: []testField{{Name: "ID"}}},
+				{Name: "Outer", Embedded: []string{"*Inner"}},
+			},
+			want:     map[string]string{"ID": "Inner"},
+			optional: []string{"ID"},
+		},
+	}
+
+	for _, test := range tests {
+		*embedding = "flatten"
+		if test.embedding != "" {
+			*embedding = test.embedding
+		}
+
+		defStore := make(DefinitionStore)
+
+		// Embedded types are embedded before the types embedding them.
+		var def *DefinitionIntermediate
+		for _, s := range test.structs {
+			def = newTestDefinition(s)
+			defStore.Add(def)
+
+			_, err := embedDefinitions(defStore, def, make(map[string]bool))
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+		}
+
+		got := make(map[string]string)
+		for _, member := range def.Members {
+			jsonName, _ := memberJsonName(member)
+			got[jsonName] = member.(*MemberIntermediate).Description
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got the fields %v; want %v", test.name, got, test.want)
+		}
+
+		var optional []string
+		for name := range def.Members {
+			if def.Optional[name] {
+				optional = append(optional, name)
+			}
+		}
+		sort.Strings(optional)
+
+		if !reflect.DeepEqual(optional, test.optional) {
+			t.Errorf("%s: got the optional fields %v; want %v", test.name, optional, test.optional)
+		}
+
+		var bases []string
+		for _, base := range def.Bases {
+			bases = append(bases, base.Name)
+		}
+
+		if !reflect.DeepEqual(bases, test.bases) {
+			t.Errorf("%s: got the bases %v; want %v", test.name, bases, test.bases)
+		}
+	}
+}
+
+func TestPromoteMethods(t *testing.T) {
+
+	type embeddedMethods struct {
+		Methods map[string]bool // map[name]pointerReceiver
+		Depths  map[string]int
+		Pointer bool
+	}
+
+	tests := []struct {
+		name     string
+		own      map[string]bool
+		embedded []embeddedMethods
+		want     map[string]bool
+		depths   map[string]int
+	}{
+		{
+			name:     "methods are promoted",
+			embedded: []embeddedMethods{{Methods: map[string]bool{"MarshalJSON": false}}},
+			want:     map[string]bool{"MarshalJSON": false},
+			depths:   map[string]int{"MarshalJSON": 1},
+		},
+		{
+			name:     "own methods win",
+			own:      map[string]bool{"MarshalJSON": true},
+			embedded: []embeddedMethods{{Methods: map[string]bool{"MarshalJSON": false}}},
+			want:     map[string]bool{"MarshalJSON": true},
+			depths:   map[string]int{},
+		},
+		{
+			name: "shallower methods win",
+			embedded: []embeddedMethods{
+				{Methods: map[string]bool{"MarshalText": true}, Depths: map[string]int{"MarshalText": 1}},
+				{Methods: map[string]bool{"MarshalText": false}},
+			},
+			want:   map[string]bool{"MarshalText": false},
+			depths: map[string]int{"MarshalText": 1},
+		},
+		{
+			name: "ambiguous methods aren't promoted",
+			embedded: []embeddedMethods{
+				{Methods: map[string]bool{"MarshalJSON": false, "String": false}},
+				{Methods: map[string]bool{"MarshalJSON": false}},
+			},
+			want:   map[string]bool{"String": false},
+			depths: map[string]int{"String": 1},
+		},
+		{
+			name: "pointer methods of pointers are value methods",
+			embedded: []embeddedMethods{
+				{Methods: map[string]bool{"MarshalJSON": true}, Pointer: true},
+				{Methods: map[string]bool{"MarshalText": true}},
+			},
+			want:   map[string]bool{"MarshalJSON": false, "MarshalText": true},
+			depths: map[string]int{"MarshalJSON": 1, "MarshalText": 1},
+		},
+	}
+
+	for _, test := range tests {
+		def := newTestDefinition(testStruct{Name: "Outer"})
+		for name, pointerReceiver := range test.own {
+			def.Methods[name] = pointerReceiver
+		}
+
+		embedded := make([]*DefinitionIntermediate, 0)
+		pointers := make(map[*DefinitionIntermediate]bool)
+		for _, e := range test.embedded {
+			embeddedDef := newTestDefinition(testStruct{Name: "Inner"})
+			embeddedDef.Methods = e.Methods
+			if e.Depths != nil {
+				embeddedDef.MethodDepths = e.Depths
+			}
+
+			embedded = append(embedded, embeddedDef)
+			pointers[embeddedDef] = e.Pointer
+		}
+
+		promoteMethods(def, embedded, pointers)
+
+		if !reflect.DeepEqual(def.Methods, test.want) {
+			t.Errorf("%s: got the methods %v; want %v", test.name, def.Methods, test.want)
+		}
+
+		if !reflect.DeepEqual(def.MethodDepths, test.depths) {
+			t.Errorf("%s: got the method depths %v; want %v", test.name, def.MethodDepths, test.depths)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+/*
+Instantiations of generic types are written as in Go, e.g. 'Page[Village]' or
+'types.Envelope[types.Page[types.Village],types.Error]'. Each instantiation is
+a definition of its own, found by substituting the type arguments for the type
+parameters of the generic type.
+
+The generic type may be declared in a package that doesn't know the packages of
+the type arguments, so the type arguments are qualified by their import paths
+(e.g. 'Page[example.com/app/types.Village]') before they're substituted. Such
+qualified types are understood wherever a package alias is.
+
+The definitions are named readably, e.g. 'PageOfVillage' and
+'EnvelopeOfPageOfVillageAndError'.
+*/
+
+// This splits a type into its package qualifier (an alias or an import path)
+// and its name, e.g. 'types.Page[types.Village]' into 'types' and
+// 'Page[types.Village]'. The qualifier of an unqualified type is empty.
+func splitTypeName(goType string) (string, string) {
+
+	base := goType
+	if idx := strings.Index(base, "["); idx > -1 {
+		base = base[:idx]
+	}
+
+	if idx := strings.LastIndex(base, "."); idx > -1 {
+		return goType[:idx], goType[idx+1:]
+	}
+
+	return "", goType
+}
+
+// This splits the name of an instantiated generic type into the name of the
+// generic type and its type arguments, e.g. 'Page[Village]' into 'Page' and
+// ['Village']. Other types have no type arguments.
+func parseTypeArgs(name string) (string, []string) {
+
+	idx := strings.Index(name, "[")
+	if idx <= 0 || strings.HasPrefix(name, "map[") || strings.HasPrefix(name, "*") {
+		return name, nil
+	}
+
+	end := matchingBracket(name, idx)
+	if end != len(name)-1 {
+		return name, nil
+	}
+
+	args := make([]string, 0)
+	depth, start := 0, idx+1
+	for i := start; i < end; i++ {
+		switch name[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(name[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	args = append(args, strings.TrimSpace(name[start:end]))
+
+	return name[:idx], args
+}
+
+func formatTypeArgs(name string, args []string) string {
+
+	if len(args) == 0 {
+		return name
+	}
+
+	return name + "[" + strings.Join(args, ",") + "]"
+}
+
+// This qualifies the named types of the type by their import paths, as
+// referenced in the given package.
+func absoluteType(referringPackage, goType string) string {
+
+	if strings.HasPrefix(goType, "*") {
+		return "*" + absoluteType(referringPackage, goType[1:])
+	}
+
+	if isMap, k, v := IsMap(goType); isMap {
+		return "map[" + absoluteType(referringPackage, k) + "]" + absoluteType(referringPackage, v)
+	}
+
+	if strings.HasPrefix(goType, "[") {
+		if end := matchingBracket(goType, 0); end > -1 {
+			return goType[:end+1] + absoluteType(referringPackage, goType[end+1:])
+		}
+	}
+
+	if isPrimitive, _, _ := IsPrimitive(goType); isPrimitive || goType == "struct" {
+		return goType
+	}
+
+	goType = absoluteTypeArgs(referringPackage, goType)
+
+	qualifier, name := splitTypeName(goType)
+	if qualifier == "" {
+		return referringPackage + "." + name
+	}
+
+	if importPaths := possibleImportPaths(pkgInfos[referringPackage], goType); len(importPaths) == 1 {
+		return importPaths[0] + "." + name
+	}
+
+	return goType
+}
+
+// This qualifies the type arguments of an instantiated generic type by their
+// import paths, leaving the generic type as it is.
+func absoluteTypeArgs(referringPackage, goType string) string {
+
+	qualifier, name := splitTypeName(goType)
+
+	name, args := parseTypeArgs(name)
+	for i, arg := range args {
+		args[i] = absoluteType(referringPackage, arg)
+	}
+	name = formatTypeArgs(name, args)
+
+	if qualifier != "" {
+		name = qualifier + "." + name
+	}
+
+	return name
+}
+
+// This replaces the type parameters in the type with the corresponding type
+// arguments.
+func substituteTypeParams(goType string, typeArgs map[string]string) string {
+
+	if len(typeArgs) == 0 {
+		return goType
+	}
+
+	rxIdentifier := regexp.MustCompile(`[\w./-]+`)
+
+	return rxIdentifier.ReplaceAllStringFunc(goType, func(identifier string) string {
+		if arg, ok := typeArgs[identifier]; ok {
+			return arg
+		}
+		return identifier
+	})
+}
+
+// This returns the readable name of a type, without its package, e.g.
+// 'PageOfVillage' for 'types.Page[types.Village]'.
+func typeDisplayName(goType string) string {
+
+	goType = strings.TrimLeft(goType, "*")
+
+	_, name := splitTypeName(goType)
+	name, args := parseTypeArgs(name)
+	if len(args) == 0 {
+		return name
+	}
+
+	names := make([]string, 0, len(args))
+	for _, arg := range args {
+		names = append(names, typeArgDisplayName(arg))
+	}
+
+	return name + "Of" + strings.Join(names, "And")
+}
+
+func typeArgDisplayName(goType string) string {
+
+	goType = strings.TrimLeft(goType, "*")
+
+	if goType == "[]byte" || goType == "[]uint8" {
+		return "Bytes"
+	}
+
+	if isMap, _, v := IsMap(goType); isMap {
+		return "MapOf" + typeArgDisplayName(v)
+	}
+
+	if isSlice, v := IsSlice(goType); isSlice {
+		return "ArrayOf" + typeArgDisplayName(v)
+	}
+
+	if goType == "interface{}" || goType == "any" {
+		return "Any"
+	}
+
+	name := []rune(typeDisplayName(goType))
+	name[0] = unicode.ToUpper(name[0])
+
+	return string(name)
+}
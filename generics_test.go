@@ -0,0 +1,145 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitTypeName(t *testing.T) {
+
+	tests := []struct {
+		goType    string
+		qualifier string
+		name      string
+	}{
+		{"Village", "", "Village"},
+		{"types.Village", "types", "Village"},
+		{"example.com/app/types.Village", "example.com/app/types", "Village"},
+		{"types.Page[types.Village]", "types", "Page[types.Village]"},
+		{"Page[example.com/app/types.Village]", "", "Page[example.com/app/types.Village]"},
+	}
+
+	for _, test := range tests {
+		qualifier, name := splitTypeName(test.goType)
+		if qualifier != test.qualifier || name != test.name {
+			t.Errorf("splitTypeName(%q) = %q, %q; want %q, %q", test.goType, qualifier, name, test.qualifier, test.name)
+		}
+	}
+}
+
+func TestParseTypeArgs(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		generic string
+		args    []string
+	}{
+		{"Village", "Village", nil},
+		{"Page[Village]", "Page", []string{"Village"}},
+		{"Pair[types.Village, *types.Error]", "Pair", []string{"types.Village", "*types.Error"}},
+		{"Envelope[Page[Village],Error]", "Envelope", []string{"Page[Village]", "Error"}},
+		{"Page[map[string][]Village]", "Page", []string{"map[string][]Village"}},
+		{"Page[struct{X, Y int}]", "Page", []string{"struct{X, Y int}"}},
+		{"[]Village", "[]Village", nil},
+		{"[4]Village", "[4]Village", nil},
+		{"map[string]Village", "map[string]Village", nil},
+		{"*Page[Village]", "*Page[Village]", nil},
+		{"Page[Village]Extra", "Page[Village]Extra", nil},
+	}
+
+	for _, test := range tests {
+		generic, args := parseTypeArgs(test.name)
+		if generic != test.generic || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("parseTypeArgs(%q) = %q, %q; want %q, %q", test.name, generic, args, test.generic, test.args)
+		}
+	}
+}
+
+func TestSubstituteTypeParams(t *testing.T) {
+
+	tests := []struct {
+		goType   string
+		typeArgs map[string]string
+		want     string
+	}{
+		{"T", map[string]string{"T": "types.Village"}, "types.Village"},
+		{"[]*T", map[string]string{"T": "types.Village"}, "[]*types.Village"},
+		{"map[K]V", map[string]string{"K": "string", "V": "int"}, "map[string]int"},
+		{"Pair[T, U]", map[string]string{"T": "A", "U": "B"}, "Pair[A, B]"},
+		{"Total", map[string]string{"T": "types.Village"}, "Total"},
+		{"types.T", map[string]string{"T": "types.Village"}, "types.T"},
+		{"T", nil, "T"},
+	}
+
+	for _, test := range tests {
+		if got := substituteTypeParams(test.goType, test.typeArgs); got != test.want {
+			t.Errorf("substituteTypeParams(%q, %v) = %q; want %q", test.goType, test.typeArgs, got, test.want)
+		}
+	}
+}
+
+func TestTypeDisplayName(t *testing.T) {
+
+	tests := []struct {
+		goType string
+		want   string
+	}{
+		{"types.Village", "Village"},
+		{"*types.Village", "Village"},
+		{"types.Page[types.Village]", "PageOfVillage"},
+		{"Page[example.com/app/types.Village]", "PageOfVillage"},
+		{"types.Envelope[types.Page[types.Village],types.Error]", "EnvelopeOfPageOfVillageAndError"},
+		{"Page[*Village]", "PageOfVillage"},
+		{"Page[[]Village]", "PageOfArrayOfVillage"},
+		{"Page[[4]Village]", "PageOfArrayOfVillage"},
+		{"Page[map[string]Village]", "PageOfMapOfVillage"},
+		{"Page[[]byte]", "PageOfBytes"},
+		{"Page[[]uint8]", "PageOfBytes"},
+		{"Page[any]", "PageOfAny"},
+		{"Page[interface{}]", "PageOfAny"},
+		{"Page[string]", "PageOfString"},
+	}
+
+	for _, test := range tests {
+		if got := typeDisplayName(test.goType); got != test.want {
+			t.Errorf("typeDisplayName(%q) = %q; want %q", test.goType, got, test.want)
+		}
+	}
+}
+
+func TestAbsoluteType(t *testing.T) {
+
+	defer func(saved map[string]PackageInfo) { pkgInfos = saved }(pkgInfos)
+
+	pkgInfos = map[string]PackageInfo{
+		"example.com/app": {
+			ImportPath:  "example.com/app",
+			PackageName: "main",
+			Imports: map[string][]string{
+				"example.com/app/types": {"types"},
+				"example.com/app/more":  {"other"},
+			},
+		},
+	}
+
+	tests := []struct {
+		goType string
+		want   string
+	}{
+		{"Village", "example.com/app.Village"},
+		{"types.Village", "example.com/app/types.Village"},
+		{"other.Color", "example.com/app/more.Color"},
+		{"*types.Village", "*example.com/app/types.Village"},
+		{"[]types.Village", "[]example.com/app/types.Village"},
+		{"map[string]types.Village", "map[string]example.com/app/types.Village"},
+		{"types.Page[types.Village]", "example.com/app/types.Page[example.com/app/types.Village]"},
+		{"string", "string"},
+		{"unknown.Village", "unknown.Village"},
+	}
+
+	for _, test := range tests {
+		if got := absoluteType("example.com/app", test.goType); got != test.want {
+			t.Errorf("absoluteType(%q) = %q; want %q", test.goType, got, test.want)
+		}
+	}
+}
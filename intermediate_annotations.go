@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"github.com/go-openapi/spec"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Route definitions may also be written with the @-annotations of
+// yvasiyarov/swagger. Such a block is recognized by its '@Router' annotation,
+// and must not also use the 'OpenAPI Path:' section.
+func isAnnotatedOperation(commentBlock string) bool {
+	commentBlock_ := strings.ToLower(commentBlock)
+	return strings.Contains(commentBlock_, "@router") && !strings.Contains(commentBlock_, "openapi path:")
+}
+
+// This produces the same intermediate as intermediatateOperation, from a block
+// in the @-annotation dialect.
+func intermediatateAnnotatedOperation(commentBlock string) OperationIntermediate {
+
+	/*
+		@Title getVillagesByWorld
+		@Description Lists the villages of a world.
+		@Accept  json
+		@Produce json
+		@Param   world  path   string         true   "World UUID"
+		@Param   x      query  int            false  "X-coordinate"
+		@Param   body   body   types.Village  true   "The village"
+		@Success 200 {array}  types.Village
+		@Failure 404 {object} types.Error  "No such world."
+		@Resource /villages
+		@Router /api/worlds/{world}/villages [get]
+	*/
+
+	var (
+		oi OperationIntermediate = OperationIntermediate{
+			Consumes:   make([]string, 0),
+			Examples:   make([]ExampleIntermediate, 0),
+			Extensions: make(spec.Extensions),
+			Parameters: make([]ParameterIntermediate, 0),
+			Produces:   make([]string, 0),
+			Raw:        make([]RawIntermediate, 0),
+			Responses:  make([]*ResponseIntermediate, 0),
+			Tags:       make([]string, 0),
+		}
+		keyword     string   // The annotation continued by lines without a keyword.
+		description []string = make([]string, 0)
+	)
+
+	scnr := bufio.NewScanner(strings.NewReader(commentBlock))
+	for scnr.Scan() {
+		line := strings.TrimSpace(scnr.Text())
+
+		if !strings.HasPrefix(line, "@") {
+			// Only descriptions span multiple lines.
+			if keyword == "@description" && line != "" {
+				description = append(description, line)
+			}
+			continue
+		}
+
+		words := splitAnnotation(line)
+		keyword = strings.ToLower(words[0])
+		args := words[1:]
+		rest := strings.TrimSpace(strings.TrimPrefix(line, words[0]))
+
+		switch keyword {
+		case "@title":
+			oi.OperationId = rest
+		case "@summary":
+			oi.Summary = rest
+		case "@description":
+			if rest != "" {
+				description = append(description, rest)
+			}
+		case "@accept", "@consumes":
+			oi.Consumes = appendMediaTypes(oi.Consumes, parseMediaTypes(strings.Fields(rest))...)
+		case "@produce", "@produces":
+			oi.Produces = appendMediaTypes(oi.Produces, parseMediaTypes(strings.Fields(rest))...)
+		case "@param":
+			if param, ok := parseParamAnnotation(args); ok {
+				oi.Parameters = append(oi.Parameters, param)
+			} else {
+				log.Print("WARNING: Malformed @Param annotation: " + line)
+			}
+		case "@success", "@failure":
+			if response, ok := parseResponseAnnotation(args); ok {
+				response.Success = keyword == "@success"
+				oi.Responses = append(oi.Responses, response)
+			} else {
+				log.Printf("WARNING: Malformed %s annotation: %s", words[0], line)
+			}
+		case "@router":
+			if len(args) == 0 {
+				log.Print("WARNING: Malformed @Router annotation: " + line)
+				continue
+			}
+
+			oi.Path = args[0]
+			oi.Method = "GET"
+			if len(args) > 1 {
+				oi.Method = strings.Trim(args[1], "[]")
+			}
+		case "@resource":
+			// Resources group operations, just like tags.
+			if tag := strings.Trim(rest, "/"); tag != "" {
+				oi.Tags = append(oi.Tags, tag)
+			}
+		default:
+			log.Print("WARNING: Unrecognized annotation: " + line)
+		}
+	}
+
+	if len(description) > 0 {
+		oi.Description = strings.Join(description, "\n")
+	}
+
+	return oi
+}
+
+// Annotation arguments are separated by whitespace, except inside double
+// quotes. The quotes are kept so that descriptions can be told apart from
+// types.
+func splitAnnotation(line string) []string {
+
+	rx := regexp.MustCompile(`"(?:[^"\\]|\\.)*"|\S+`)
+
+	return rx.FindAllString(line, -1)
+}
+
+func unquoteAnnotation(word string) string {
+
+	if s, err := strconv.Unquote(word); err == nil {
+		return s
+	}
+
+	return strings.Trim(word, "\"")
+}
+
+// The parameter locations of yvasiyarov/swagger are those of Swagger 2.0,
+// except 'form'.
+func parseParamAnnotation(args []string) (ParameterIntermediate, bool) {
+
+	/*
+		@Param   world  path  string  true  "World UUID"
+	*/
+
+	if len(args) < 3 {
+		return ParameterIntermediate{}, false
+	}
+
+	var (
+		name     string = args[0]
+		in       string = args[1]
+		goType   string = annotationType(args[2])
+		required bool
+		desc     []string = make([]string, 0)
+	)
+
+	switch strings.ToLower(in) {
+	case "path", "query", "header", "body":
+		in = strings.ToLower(in)
+	case "form", "formdata":
+		in = "formData"
+	default:
+		return ParameterIntermediate{}, false
+	}
+
+	for i, arg := range args[3:] {
+		if i == 0 && !strings.HasPrefix(arg, "\"") {
+			switch strings.ToLower(arg) {
+			case "true", "required":
+				required = true
+				continue
+			case "false", "optional":
+				continue
+			}
+		}
+
+		desc = append(desc, unquoteAnnotation(arg))
+	}
+
+	param := ParameterIntermediate{
+		Name:        name,
+		In:          in,
+		Required:    required || in == "path",
+		Description: strings.Join(desc, " "),
+	}
+
+	if in == "body" {
+		param.Type = intermediatateType(goType)
+		param.MediaTypes = make([]MediaTypeIntermediate, 0)
+	} else {
+		param.Type = &MemberIntermediate{
+			Type:     goType,
+			JsonName: name,
+		}
+	}
+
+	return param, true
+}
+
+// The type of a response is preceded by its kind, e.g. '{array}'. The kind of
+// a primitive type stands in for the type itself.
+func parseResponseAnnotation(args []string) (*ResponseIntermediate, bool) {
+
+	/*
+		@Success 200 {array}  types.Village
+		@Failure 404 {object} types.Error  "No such world."
+		@Success 204
+	*/
+
+	if len(args) == 0 {
+		return nil, false
+	}
+
+	statusCode, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, false
+	}
+	args = args[1:]
+
+	var (
+		kind   string
+		goType string = "nil"
+	)
+
+	if len(args) > 0 && strings.HasPrefix(args[0], "{") {
+		kind = strings.ToLower(strings.Trim(args[0], "{}"))
+		args = args[1:]
+
+		if kind != "object" && kind != "array" {
+			goType = annotationType(kind)
+		}
+	}
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "\"") {
+		goType = annotationType(args[0])
+		args = args[1:]
+	}
+
+	if kind == "array" {
+		goType = "[]" + goType
+	}
+
+	desc := make([]string, 0, len(args))
+	for _, arg := range args {
+		desc = append(desc, unquoteAnnotation(arg))
+	}
+
+	response := &ResponseIntermediate{
+		StatusCode:  statusCode,
+		Description: strings.Join(desc, " "),
+		Type:        intermediatateType(goType),
+	}
+
+	if response.Description == "" {
+		response.Description = http.StatusText(statusCode)
+	}
+
+	return response, true
+}
+
+// Types may be given as Swagger types, or as Go types qualified by their full
+// package path (e.g. 'my_api.model.OrderRow'), which is reduced to the package
+// name and type.
+func annotationType(t string) string {
+
+	switch strings.ToLower(t) {
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "file":
+		return "io.Reader"
+	}
+
+	qualifier, name := splitTypeName(t)
+	if idx := strings.LastIndex(qualifier, "."); idx > -1 {
+		qualifier = qualifier[idx+1:]
+	}
+
+	// Generic types are instantiated with types given the same way.
+	name, args := parseTypeArgs(name)
+	for i, arg := range args {
+		args[i] = annotationType(arg)
+	}
+	name = formatTypeArgs(name, args)
+
+	if qualifier != "" {
+		name = qualifier + "." + name
+	}
+
+	return name
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"github.com/go-openapi/spec"
+	"log"
 	"strings"
 )
 
@@ -9,9 +11,22 @@ type ApiIntermediate struct {
 	ApiTitle       string
 	ApiDescription string
 	BasePath       string
+	Host           string
+	Schemes        []string
+	TermsOfService string
+	ContactName    string
+	ContactEmail   string
+	ContactUrl     string
+	LicenseName    string
+	LicenseUrl     string
+	ExternalDocs   *ExternalDocsIntermediate
+	Consumes       []string // The default for all operations.
+	Produces       []string // The default for all operations.
+	Extensions     spec.Extensions
+	Raw            []RawIntermediate
 }
 
-func intermediatateApi(commentBlocks []string) ApiIntermediate {
+func intermediatateApi(commentBlocks []CommentBlock) ApiIntermediate {
 
 	/*
 		OpenAPI API Title:
@@ -23,13 +38,51 @@ func intermediatateApi(commentBlocks []string) ApiIntermediate {
 			1.0
 		OpenAPI Base Path:
 			/api
+		OpenAPI Host:
+			api.agame.com
+		OpenAPI Schemes:
+			https
+		OpenAPI Terms Of Service:
+			https://agame.com/terms
+		OpenAPI Contact Name:
+			Agame API Team
+		OpenAPI Contact Email:
+			api@agame.com
+		OpenAPI Contact URL:
+			https://agame.com/support
+		OpenAPI License Name:
+			MIT
+		OpenAPI License URL:
+			https://opensource.org/licenses/MIT
+		OpenAPI External Docs:
+			https://agame.com/docs  The Agame developer guide.
+		OpenAPI Content Type:
+			application/json
+		OpenAPI Extensions:
+			x-audience  public
+		OpenAPI Raw:
+			securityDefinitions:
+			  api_key:
+			    type: apiKey
+			    name: X-API-Key
+			    in: header
 	*/
 
-	var apiIntermediate ApiIntermediate = ApiIntermediate{}
+	var apiIntermediate ApiIntermediate = ApiIntermediate{
+		Consumes:   make([]string, 0),
+		Produces:   make([]string, 0),
+		Schemes:    make([]string, 0),
+		Extensions: make(spec.Extensions),
+		Raw:        make([]RawIntermediate, 0),
+	}
 
 	for _, commentBlock := range commentBlocks {
 
-		sections := parseSections(commentBlock)
+		sections := parseSections(commentBlock.Text)
+
+		// API and tag blocks may be combined. Once a tag is declared, the
+		// sections that follow belong to the tag.
+		inTag := false
 
 		for _, section := range sections {
 
@@ -53,6 +106,58 @@ func intermediatateApi(commentBlocks []string) ApiIntermediate {
 				if l, ok := section.Line(0); ok {
 					apiIntermediate.BasePath = l
 				}
+			case "openapi host":
+				if l, ok := section.Line(0); ok {
+					apiIntermediate.Host = getFirstWord(l)
+				}
+			case "openapi schemes":
+				apiIntermediate.Schemes = append(apiIntermediate.Schemes, parseSchemes(section.Lines())...)
+			case "openapi terms of service":
+				if l, ok := section.Line(0); ok {
+					apiIntermediate.TermsOfService = l
+				}
+			case "openapi contact name":
+				if l, ok := section.Line(0); ok {
+					apiIntermediate.ContactName = l
+				}
+			case "openapi contact email":
+				if l, ok := section.Line(0); ok {
+					apiIntermediate.ContactEmail = getFirstWord(l)
+				}
+			case "openapi contact url":
+				if l, ok := section.Line(0); ok {
+					apiIntermediate.ContactUrl = getFirstWord(l)
+				}
+			case "openapi license name":
+				if l, ok := section.Line(0); ok {
+					apiIntermediate.LicenseName = l
+				}
+			case "openapi license url":
+				if l, ok := section.Line(0); ok {
+					apiIntermediate.LicenseUrl = getFirstWord(l)
+				}
+			case "openapi content type":
+				mediaTypes := parseMediaTypes(section.Lines())
+				apiIntermediate.Consumes = appendMediaTypes(apiIntermediate.Consumes, mediaTypes...)
+				apiIntermediate.Produces = appendMediaTypes(apiIntermediate.Produces, mediaTypes...)
+			case "openapi consumes":
+				apiIntermediate.Consumes = appendMediaTypes(apiIntermediate.Consumes, parseMediaTypes(section.Lines())...)
+			case "openapi produces":
+				apiIntermediate.Produces = appendMediaTypes(apiIntermediate.Produces, parseMediaTypes(section.Lines())...)
+			case "openapi extensions":
+				for name, value := range parseExtensions(section) {
+					apiIntermediate.Extensions.Add(name, value)
+				}
+			case "openapi raw":
+				if raw, ok := parseRaw(commentBlock, section); ok {
+					apiIntermediate.Raw = append(apiIntermediate.Raw, raw)
+				}
+			case "openapi tag":
+				inTag = true
+			case "openapi external docs":
+				if !inTag {
+					apiIntermediate.ExternalDocs = parseExternalDocs(section)
+				}
 			}
 		}
 	}
@@ -60,6 +165,33 @@ func intermediatateApi(commentBlocks []string) ApiIntermediate {
 	return apiIntermediate
 }
 
+// The Swagger spec only allows a handful of transfer protocols. Anything else
+// is reported and dropped. Schemes may be given one per line or separated by
+// commas.
+func parseSchemes(lines []string) []string {
+
+	schemes := make([]string, 0)
+
+	for _, l := range lines {
+		for _, scheme := range strings.Split(l, ",") {
+			scheme = strings.ToLower(strings.TrimSpace(scheme))
+
+			switch scheme {
+			case "":
+				continue
+			case "http", "https", "ws", "wss":
+				if !sContains(schemes, scheme) {
+					schemes = append(schemes, scheme)
+				}
+			default:
+				log.Print("WARNING: Unrecognized scheme: " + scheme)
+			}
+		}
+	}
+
+	return schemes
+}
+
 func getFirstWord(s string) string {
 	words := strings.Split(s, " ")
 	for _, word := range words {
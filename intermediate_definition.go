@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"github.com/go-openapi/spec"
 	"strings"
 )
@@ -11,10 +12,39 @@ type DefinitionIntermediate struct {
 	EmbeddedTypes  []string
 	Members        map[string]SchemerDefiner // map[name]schemer
 	Name           string
-	PackageName    string        // The actual package name of this type.
-	PackagePath    string        // The actual package path of this type.
-	UnderlyingType string        // This isn't used right now. In our test codebase, non-struct types were never used.
-	Enums          []interface{} // If the underlying type is a primitive type, it's assumed it's an enum type, these being the values.
+	PackageName    string             // The actual package name of this type.
+	PackagePath    string             // The actual package path of this type.
+	UnderlyingType string             // This isn't used right now. In our test codebase, non-struct types were never used.
+	Enums          []EnumIntermediate // If the underlying type is a primitive type, it's assumed it's an enum type, these being the values.
+	ExternalDocs   *ExternalDocsIntermediate
+	Example        json.RawMessage
+	ExampleRef     string // A Go variable providing the example, e.g. 'ExampleVillage'.
+	Extensions     spec.Extensions
+	Deprecated     bool
+	Methods        map[string]bool // map[name]pointerReceiver
+	SchemaType     string          // The Swagger type of a type that marshals itself, if any.
+	SchemaFormat   string
+
+	// Methods are promoted from embedded types like fields are, and remember
+	// how deeply they were embedded. '@schema go' describes the Go
+	// declaration, whatever the methods.
+	MethodDepths     map[string]int // map[name]depth
+	IgnoreMarshalers bool
+
+	// Embedded structs are either composed with allOf, as bases, or have their
+	// fields promoted to this definition's members. Promoted members remember
+	// how deeply they were embedded, and those promoted through an embedded
+	// pointer are never required, as they're left out when it's nil.
+	Bases    []*DefinitionIntermediate
+	Depths   map[string]int  // map[name]depth
+	Optional map[string]bool // map[name]optional
+
+	// An interface may list the types implementing it, which are told apart by
+	// the value of the discriminator property. Each implementation has the
+	// interface as its first base.
+	Discriminator      string
+	Implementations    []ImplementationIntermediate
+	DiscriminatorValue string // The value identifying this implementation, if not its name.
 
 	// While it may not strictly be equivalent from a language specification
 	// perspective, we're going to call a non-struct type with an underlying
@@ -24,6 +54,30 @@ type DefinitionIntermediate struct {
 	// types for Swagger.
 }
 
+// A value of an enum type, named by its constant.
+type EnumIntermediate struct {
+	Name        string
+	Value       interface{}
+	Description string
+}
+
+type ImplementationIntermediate struct {
+	Value      string // The value of the discriminator; the definition's name by default.
+	Type       string // Go type, relative to the package of the interface.
+	Definition *DefinitionIntermediate
+}
+
+// Swagger 2.0 identifies an implementation by its definition's name, unless
+// 'x-discriminator-value' says otherwise.
+func (this ImplementationIntermediate) DiscriminatorValue() string {
+
+	if this.Value != "" {
+		return this.Value
+	}
+
+	return this.Definition.SwaggerName()
+}
+
 func (this *DefinitionIntermediate) CanonicalName() string {
 	name := this.PackagePath + "." + this.Name
 	name = strings.Replace(name, "/", ".", -1)
@@ -36,11 +90,11 @@ func (this *DefinitionIntermediate) SwaggerName() string {
 
 	switch *naming {
 	case "full":
-		name = this.CanonicalName()
+		name = strings.Replace(this.PackagePath, "/", ".", -1) + "." + typeDisplayName(this.Name)
 	case "partial":
-		name = this.PackageName + "." + this.Name
+		name = this.PackageName + "." + typeDisplayName(this.Name)
 	case "simple":
-		name = this.Name
+		name = typeDisplayName(this.Name)
 	}
 
 	return name
@@ -50,31 +104,198 @@ func (this *DefinitionIntermediate) Schema() spec.Schema {
 
 	var schema spec.Schema
 	schema.Title = this.SwaggerName()
+	schema.ExternalDocs = this.ExternalDocs.ExternalDocs()
+	addExtensions(&schema.VendorExtensible, this.Extensions)
+
+	if this.Deprecated {
+		schema.AddExtension("x-deprecated", true)
+	}
+
+	if this.Example != nil {
+		schema.Example = this.Example
+	}
 
-	if isPrimitive, t, f := IsPrimitive(this.UnderlyingType); isPrimitive {
+	if this.DiscriminatorValue != "" {
+		schema.AddExtension("x-discriminator-value", this.DiscriminatorValue)
+	}
+
+	if this.SchemaType != "" {
+		schema.Typed(this.SchemaType, this.SchemaFormat)
+	} else if this.Discriminator != "" {
+		schema.Typed("object", "")
+		schema.WithDiscriminator(this.Discriminator)
+		schema.Required = []string{this.Discriminator}
+
+		values := make([]interface{}, 0, len(this.Implementations))
+		for _, implementation := range this.Implementations {
+			if implementation.Definition == nil {
+				continue
+			}
+
+			values = append(values, implementation.DiscriminatorValue())
+		}
+
+		discriminator := spec.StringProperty()
+		discriminator.Title = this.Discriminator
+		discriminator.Enum = values
+		schema.SetProperty(this.Discriminator, *discriminator)
+	} else if isPrimitive, t, f := IsPrimitive(this.UnderlyingType); isPrimitive {
 		schema.Typed(t, f)
-		schema.Enum = this.Enums
+		this.describeEnums(&schema)
 	} else {
-		schema.Typed("object", "")
-		schema.Required = make([]string, 0)
+		object := &schema
+
+		// The definition's own members follow the references to its bases.
+		if len(this.Bases) > 0 {
+			for _, base := range this.Bases {
+				schema.AllOf = append(schema.AllOf, *spec.RefSchema("#/definitions/" + base.SwaggerName()))
+			}
+
+			schema.AllOf = append(schema.AllOf, spec.Schema{})
+			object = &schema.AllOf[len(schema.AllOf)-1]
+		}
+
+		object.Typed("object", "")
+		object.Required = make([]string, 0)
 
 		properties := make(map[string]spec.Schema)
-		for _, member := range this.Members {
+		for name, member := range this.Members {
 			property := member.Schema()
 			properties[property.Title] = *property
 
-			if member.IsRequired() {
-				schema.Required = append(schema.Required, property.Title)
+			if this.IsMemberRequired(name) {
+				object.Required = append(object.Required, property.Title)
 			}
 
 		}
 
-		schema.Properties = properties
+		object.Properties = properties
 	}
 
 	return schema
 }
 
+// Generated clients name the members of an enum by 'x-enum-varnames', and may
+// document them by 'x-enum-descriptions'. For everyone else, the description
+// lists them in a table.
+func (this *DefinitionIntermediate) describeEnums(schema *spec.Schema) {
+
+	if len(this.Enums) == 0 {
+		return
+	}
+
+	var (
+		names        []string = make([]string, 0, len(this.Enums))
+		descriptions []string = make([]string, 0, len(this.Enums))
+		described    bool
+	)
+
+	for _, enum := range this.Enums {
+		schema.Enum = append(schema.Enum, enum.Value)
+		names = append(names, enum.Name)
+		descriptions = append(descriptions, enum.Description)
+		described = described || enum.Description != ""
+	}
+
+	schema.AddExtension("x-enum-varnames", names)
+	if described {
+		schema.AddExtension("x-enum-descriptions", descriptions)
+	}
+
+	table := []string{"| Name | Value |", "| --- | --- |"}
+	if described {
+		table = []string{"| Name | Value | Description |", "| --- | --- | --- |"}
+	}
+
+	for i, enum := range this.Enums {
+		value, _ := json.Marshal(enum.Value)
+		row := []string{names[i], "`" + string(value) + "`"}
+		if described {
+			row = append(row, strings.Replace(descriptions[i], "|", "\\|", -1))
+		}
+
+		table = append(table, "| "+strings.Join(row, " | ")+" |")
+	}
+
+	schema.Description = strings.Join(table, "\n")
+}
+
+// This tells whether a value of the type has the method. Only a pointer to the
+// type has the methods with pointer receivers.
+func (this *DefinitionIntermediate) HasMethod(name string, pointer bool) bool {
+	pointerReceiver, ok := this.Methods[name]
+	return ok && (pointer || !pointerReceiver)
+}
+
+func (this *DefinitionIntermediate) IsMemberRequired(name string) bool {
+
+	if member, ok := this.Members[name]; ok {
+		return member.IsRequired() && !this.Optional[name]
+	}
+
+	for _, base := range this.Bases {
+		if base.IsMemberRequired(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// This returns the members of this definition and of its bases, i.e. every
+// property of the JSON object.
+func (this *DefinitionIntermediate) AllMembers() map[string]SchemerDefiner {
+
+	members := make(map[string]SchemerDefiner)
+
+	for _, base := range this.Bases {
+		for name, member := range base.AllMembers() {
+			members[name] = member
+		}
+	}
+
+	for name, member := range this.Members {
+		members[name] = member
+	}
+
+	return members
+}
+
+// This returns the definitions of the anonymous structs of this definition's
+// members, including those nested in them.
+func (this *DefinitionIntermediate) InlineDefinitions() []*DefinitionIntermediate {
+
+	defs := make([]*DefinitionIntermediate, 0)
+	seen := make(map[*DefinitionIntermediate]bool) // Fields like 'X, Y struct{...}' share theirs.
+
+	for _, member := range this.Members {
+		inline := inlineDefinition(member)
+		if inline == nil || seen[inline] {
+			continue
+		}
+		seen[inline] = true
+
+		defs = append(defs, inline)
+		defs = append(defs, inline.InlineDefinitions()...)
+	}
+
+	return defs
+}
+
+func inlineDefinition(member SchemerDefiner) *DefinitionIntermediate {
+
+	switch t := member.(type) {
+	case *MemberIntermediate:
+		return t.Inline
+	case *SliceIntermediate:
+		return inlineDefinition(t.ValueType)
+	case *MapIntermediate:
+		return inlineDefinition(t.ValueType)
+	}
+
+	return nil
+}
+
 //func (this *DefinitionIntermediate) DefineDefinitions() error {
 //
 //	var err error
@@ -109,13 +330,3 @@ func (this *DefinitionIntermediate) Schema() spec.Schema {
 //
 //	return nil
 //}
-
-func mergeDefinitions(dst, src *DefinitionIntermediate) {
-	for srcName, srcMember := range src.Members {
-		_, exists := dst.Members[srcName]
-		if !exists {
-			srcMember.SetPackagePath(src.PackagePath)
-			dst.Members[srcName] = srcMember
-		}
-	}
-}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/go-openapi/spec"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// An example is keyed by the status code of the response it illustrates, or by
+// 'request' for the request body.
+type ExampleIntermediate struct {
+	Key       string
+	MediaType string // Optional; only meaningful for responses.
+	Value     json.RawMessage
+	Reference string // A Go variable providing the value, e.g. 'types.ExampleVillage'.
+}
+
+// Each example begins with a line containing its key, optionally followed by a
+// media type and the JSON. The JSON may continue on the following lines.
+// Instead of JSON, the example may reference a Go variable, which is resolved
+// later.
+//
+// A key is only recognized once the JSON (or reference) of the preceding example
+// is complete, so a multi-line JSON document may safely contain lines
+// resembling keys.
+func parseExamples(section Section) []ExampleIntermediate {
+
+	/*
+		OpenAPI Examples:
+			request {"name": "Riverwood", "pop": 3}
+			200 application/json
+				[
+					{"name": "Riverwood", "pop": 3}
+				]
+			404 {"message": "No such village."}
+			409 types.ExampleConflict
+	*/
+
+	var (
+		out     []ExampleIntermediate = make([]ExampleIntermediate, 0)
+		current *ExampleIntermediate  // Leave nil until a key is identified.
+		body    *bytes.Buffer         = bytes.NewBuffer(nil)
+		rx      *regexp.Regexp        = regexp.MustCompile(`^(\d{3}|(?i:request|default))(?:\s+([\w.+-]+/[\w.+-]+))?(?:\s+(.*))?$`)
+	)
+
+	capture := func() {
+		if current == nil {
+			return
+		}
+
+		raw := bytes.TrimSpace(body.Bytes())
+		if rxExampleReference.Match(raw) && !json.Valid(raw) {
+			current.Reference = string(raw)
+			out = append(out, *current)
+			return
+		}
+
+		if !json.Valid(raw) {
+			log.Printf("WARNING: Example '%s' is not valid JSON:\n%s", current.Key, raw)
+			return
+		}
+
+		current.Value = json.RawMessage(raw)
+		out = append(out, *current)
+	}
+
+	for _, l := range section.Lines() {
+
+		raw := bytes.TrimSpace(body.Bytes())
+		complete := len(raw) == 0 || json.Valid(raw) || rxExampleReference.Match(raw)
+
+		if matches := rx.FindStringSubmatch(l); matches != nil && complete {
+			capture()
+
+			current = &ExampleIntermediate{
+				Key:       strings.ToLower(matches[1]),
+				MediaType: matches[2],
+			}
+			body = bytes.NewBufferString(matches[3])
+			continue
+		}
+
+		if current == nil {
+			log.Print("WARNING: Example has no key: " + l)
+			continue
+		}
+
+		fmt.Fprintln(body, l)
+	}
+
+	// capture the last example.
+	capture()
+
+	return out
+}
+
+// This checks every example in the document against the schema it
+// illustrates, reporting any mismatches as warnings.
+func validateExamples(swagger *spec.Swagger) {
+
+	if swagger.Paths == nil {
+		return
+	}
+
+	paths := make([]string, 0, len(swagger.Paths.Paths))
+	for path := range swagger.Paths.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		pathItem := swagger.Paths.Paths[path]
+
+		methods := []string{"GET", "PUT", "POST", "DELETE", "OPTIONS", "HEAD", "PATCH"}
+		operations := []*spec.Operation{pathItem.Get, pathItem.Put, pathItem.Post, pathItem.Delete, pathItem.Options, pathItem.Head, pathItem.Patch}
+
+		for i, operation := range operations {
+			method := methods[i]
+			if operation == nil {
+				continue
+			}
+
+			for _, parameter := range operation.Parameters {
+				example, ok := exampleJson(parameter.Extensions["x-example"])
+				if !ok {
+					continue
+				}
+
+				for _, problem := range validateExample(example, parameter.Schema, swagger.Definitions) {
+					log.Printf("WARNING: Request example of %s %s doesn't match its schema: %s", method, path, problem)
+				}
+			}
+
+			if operation.Responses == nil {
+				continue
+			}
+
+			for statusCode, response := range operation.Responses.StatusCodeResponses {
+				for mediaType, example := range response.Examples {
+					example, ok := exampleJson(example)
+					if !ok || !strings.Contains(mediaType, "json") {
+						continue
+					}
+
+					for _, problem := range validateExample(example, response.Schema, swagger.Definitions) {
+						log.Printf("WARNING: Example of response %d of %s %s doesn't match its schema: %s", statusCode, method, path, problem)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Examples are raw JSON until a raw fragment is merged into their operation,
+// after which they are decoded values that must be encoded again.
+func exampleJson(example interface{}) (json.RawMessage, bool) {
+
+	switch example := example.(type) {
+	case nil:
+		return nil, false
+	case json.RawMessage:
+		return example, true
+	}
+
+	b, err := json.Marshal(example)
+	if err != nil {
+		return nil, false
+	}
+
+	return json.RawMessage(b), true
+}
+
+// This reports the ways in which the example fails to conform to the schema.
+// Definitions are resolved from the given set. Only the structure of the
+// example is checked: property names, required properties and JSON types.
+func validateExample(example json.RawMessage, schema *spec.Schema, definitions spec.Definitions) []string {
+
+	var value interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(example))
+	dec.UseNumber()
+	err := dec.Decode(&value)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	return validateExampleValue(value, schema, definitions, "$", 0)
+}
+
+func validateExampleValue(value interface{}, schema *spec.Schema, definitions spec.Definitions, path string, depth int) []string {
+
+	problems := make([]string, 0)
+
+	// Recursive definitions are possible, but examples are not infinite.
+	if schema == nil || value == nil || depth > 64 {
+		return problems
+	}
+
+	if ref := schema.Ref.String(); ref != "" {
+		name := strings.TrimPrefix(ref, "#/definitions/")
+		definition, ok := definitions[name]
+		if !ok {
+			return problems
+		}
+
+		return validateExampleValue(value, &definition, definitions, path, depth+1)
+	}
+
+	// A reference with a description of its own is wrapped alone.
+	if len(schema.AllOf) == 1 {
+		return validateExampleValue(value, &schema.AllOf[0], definitions, path, depth+1)
+	}
+
+	// The parts of a composition describe the same object.
+	if len(schema.AllOf) > 0 {
+		composed := resolveExampleSchema(schema, definitions, depth)
+		composed.Typed("object", "")
+
+		return validateExampleValue(value, composed, definitions, path, depth+1)
+	}
+
+	mismatch := func(expected string) []string {
+		return append(problems, fmt.Sprintf("%s: expected %s, found %s", path, expected, exampleJsonType(value)))
+	}
+
+	switch {
+	case schema.Type.Contains("object"):
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch("object")
+		}
+
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property '%s'", path, name))
+			}
+		}
+
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			property, ok := schema.Properties[name]
+			if ok {
+				problems = append(problems, validateExampleValue(obj[name], &property, definitions, path+"."+name, depth+1)...)
+			} else if schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil {
+				problems = append(problems, validateExampleValue(obj[name], schema.AdditionalProperties.Schema, definitions, path+"."+name, depth+1)...)
+			} else if len(schema.Properties) > 0 {
+				problems = append(problems, fmt.Sprintf("%s: unknown property '%s'", path, name))
+			}
+		}
+	case schema.Type.Contains("array"):
+		arr, ok := value.([]interface{})
+		if !ok {
+			return mismatch("array")
+		}
+
+		if schema.Items != nil {
+			for i, item := range arr {
+				problems = append(problems, validateExampleValue(item, schema.Items.Schema, definitions, fmt.Sprintf("%s[%d]", path, i), depth+1)...)
+			}
+		}
+	case schema.Type.Contains("string"):
+		if _, ok := value.(string); !ok {
+			return mismatch("string")
+		}
+	case schema.Type.Contains("integer"):
+		n, ok := value.(json.Number)
+		if !ok {
+			return mismatch("integer")
+		}
+
+		if _, err := n.Int64(); err != nil {
+			return mismatch("integer")
+		}
+	case schema.Type.Contains("number"):
+		if _, ok := value.(json.Number); !ok {
+			return mismatch("number")
+		}
+	case schema.Type.Contains("boolean"):
+		if _, ok := value.(bool); !ok {
+			return mismatch("boolean")
+		}
+	}
+
+	return problems
+}
+
+func exampleJsonType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+
+	return "null"
+}
+
+// This follows the references to the schema, and composes the parts of any
+// composition.
+func resolveExampleSchema(schema *spec.Schema, definitions spec.Definitions, depth int) *spec.Schema {
+
+	for ; schema.Ref.String() != "" && depth <= 64; depth++ {
+		definition, ok := definitions[strings.TrimPrefix(schema.Ref.String(), "#/definitions/")]
+		if !ok {
+			return &spec.Schema{}
+		}
+		schema = &definition
+	}
+
+	if len(schema.AllOf) == 0 {
+		return schema
+	}
+
+	composed := &spec.Schema{}
+	composed.Properties = make(map[string]spec.Schema)
+	for i := range schema.AllOf {
+		part := resolveExampleSchema(&schema.AllOf[i], definitions, depth+1)
+		for name, property := range part.Properties {
+			composed.Properties[name] = property
+		}
+		composed.Required = append(composed.Required, part.Required...)
+	}
+
+	return composed
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/go-openapi/spec"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Vendor extensions must be prefixed by 'x-'. JSON doesn't allow a bare x-
+// anywhere, so a line beginning with one always starts a new extension.
+var rxExtension *regexp.Regexp = regexp.MustCompile(`^(x-[\w.-]+)(?:\s+(.*))?$`)
+
+// Each extension begins with a line containing its name, followed by its
+// value. A JSON value may continue on the following lines until it is complete.
+func parseExtensions(section Section) spec.Extensions {
+
+	/*
+		OpenAPI Extensions:
+			x-rate-limit  100
+			x-internal    true
+			x-amazon-apigateway-integration
+				{
+					"type": "http_proxy",
+					"httpMethod": "GET"
+				}
+	*/
+
+	var (
+		extensions spec.Extensions = make(spec.Extensions)
+		name       string          // Leave empty until an extension is identified.
+		body       *bytes.Buffer   = bytes.NewBuffer(nil)
+	)
+
+	capture := func() {
+		if name != "" {
+			extensions.Add(name, parseExtensionValue(body.String()))
+		}
+	}
+
+	for _, l := range section.Lines() {
+
+		if matches := rxExtension.FindStringSubmatch(l); matches != nil {
+			capture()
+
+			name = matches[1]
+			body = bytes.NewBuffer(nil)
+			fmt.Fprintln(body, matches[2])
+			continue
+		}
+
+		// A complete value can't be continued.
+		value := bytes.TrimSpace(body.Bytes())
+		if name == "" || (len(value) > 0 && json.Valid(value)) {
+			log.Print("WARNING: Vendor extensions must begin with 'x-': " + l)
+			continue
+		}
+
+		fmt.Fprintln(body, l)
+	}
+
+	// capture the last extension.
+	capture()
+
+	return extensions
+}
+
+// Extension values are JSON. For convenience, anything that isn't JSON is
+// taken as a string.
+func parseExtensionValue(s string) interface{} {
+
+	s = strings.TrimSpace(s)
+
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+
+	return s
+}
+
+func addExtensions(dst *spec.VendorExtensible, extensions spec.Extensions) {
+	for name, value := range extensions {
+		dst.AddExtension(name, value)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"github.com/go-openapi/spec"
+	"log"
+	"strings"
+)
+
+type ExternalDocsIntermediate struct {
+	Url         string
+	Description string
+}
+
+func (this *ExternalDocsIntermediate) ExternalDocs() *spec.ExternalDocumentation {
+
+	if this == nil {
+		return nil
+	}
+
+	return &spec.ExternalDocumentation{
+		URL:         this.Url,
+		Description: this.Description,
+	}
+}
+
+// The first word of the section body is the URL. Anything following it,
+// including subsequent lines, is the description.
+func parseExternalDocs(section Section) *ExternalDocsIntermediate {
+
+	/*
+		OpenAPI External Docs:
+			https://agame.com/docs/villages  Everything about villages.
+	*/
+
+	lines := section.Lines()
+	if len(lines) == 0 {
+		log.Print("WARNING: External docs section has no URL.")
+		return nil
+	}
+
+	url := strings.Fields(lines[0])[0]
+
+	desc := strings.TrimSpace(strings.TrimPrefix(lines[0], url))
+	if len(lines) > 1 {
+		desc = strings.TrimSpace(desc + "\n" + strings.Join(lines[1:], "\n"))
+	}
+
+	return &ExternalDocsIntermediate{
+		Url:         url,
+		Description: desc,
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"github.com/go-openapi/spec"
 	"strconv"
+	"strings"
 )
 
 type MapIntermediate struct {
@@ -11,16 +13,18 @@ type MapIntermediate struct {
 	JsonName      string // JSON name.
 	JsonOmitEmpty bool   // If the omitempty flag was given in the JSON.
 	KeyType       *MemberIntermediate
-	ValueType     *MemberIntermediate
+	ValueType     SchemerDefiner // Possibly a collection itself.
 	Description   string
 	Validations   Validator
 	Deprecated    bool
+	Extensions    spec.Extensions
 	PackageName   string // Necessary for canonical and swagger names.
 	PackagePath   string
+	KeySchema     *spec.Schema // How the keys are encoded, unless they're plain strings.
 }
 
 func (this *MapIntermediate) IsRequired() bool {
-	return this.Validations.IsRequired()
+	return isRequired(this.Validations, this.JsonOmitEmpty)
 }
 
 func (this *MapIntermediate) GoType() string {
@@ -50,6 +54,15 @@ func (this *MapIntermediate) Schema() *spec.Schema {
 	schema.Title = name
 
 	schema.Description = this.Description
+	addExtensions(&schema.VendorExtensible, this.Extensions)
+
+	if this.Deprecated {
+		schema.AddExtension("x-deprecated", true)
+	}
+
+	if IsNullable(this.Type) {
+		schema.AddExtension("x-nullable", true)
+	}
 	schema.Items = new(spec.SchemaOrArray)
 	schema.Items.Schema = new(spec.Schema)
 
@@ -63,6 +76,11 @@ func (this *MapIntermediate) Schema() *spec.Schema {
 	// The additional properties is the type of the value type.
 	schema.AdditionalProperties.Schema = this.ValueType.Schema()
 
+	// Swagger 2.0 can't describe the names of properties.
+	if this.KeySchema != nil {
+		schema.AddExtension("x-key-type", this.KeySchema)
+	}
+
 	// No one with whom I've spoken knows how maps work in Swagger.
 	// Consequently, I'm hoping that the validations work just as well with maps
 	// as they do with slices/arrays.
@@ -113,3 +131,99 @@ func (this *MapIntermediate) Schema() *spec.Schema {
 //
 //	return nil
 //}
+
+/*
+JSON object keys are strings, so encoding/json encodes the keys of a map as
+follows:
+
+  - A key of a string type is used as is.
+  - A key implementing encoding.TextMarshaler is encoded by MarshalText.
+  - A key of an integer type is formatted as a decimal number.
+
+Any other key type can't be marshalled. This returns the schema of the encoded
+keys, which is nil for plain strings, and whether the key type can be
+marshalled at all.
+*/
+func describeMapKey(defStore DefinitionStore, referringPackage, keyType string) (*spec.Schema, bool) {
+
+	pointer := strings.HasPrefix(keyType, "*")
+	goType := strings.TrimPrefix(keyType, "*")
+
+	switch {
+	case goType == "string":
+		return nil, !pointer
+	case goType == "time.Time":
+		// time.Time implements encoding.TextMarshaler.
+		return new(spec.Schema).Typed("string", "date-time"), true
+	case isIntegerKind(goType):
+		return integerKeySchema(goType), !pointer
+	}
+
+	if isPrimitive, _, _ := IsPrimitive(goType); isPrimitive {
+		return nil, false
+	}
+
+	if isSlice, _ := IsSlice(goType); isSlice || goType == "struct" || goType == "interface{}" {
+		return nil, false
+	}
+
+	if isMap, _, _ := IsMap(goType); isMap {
+		return nil, false
+	}
+
+	def, ok := defStore.ExistsDefinition(referringPackage, goType)
+	if !ok {
+		// There's nothing to tell.
+		return nil, true
+	}
+
+	var schema *spec.Schema
+
+	switch {
+	case def.UnderlyingType == "string" && !pointer:
+		if len(def.Enums) == 0 {
+			return nil, true
+		}
+		schema = new(spec.Schema).Typed("string", "")
+	case def.HasMethod("MarshalText", pointer):
+		schema = new(spec.Schema).Typed("string", "")
+		schema.Description = "Encoded by the MarshalText method of " + def.Name + "."
+		return schema, true
+	case isIntegerKind(def.UnderlyingType) && !pointer:
+		schema = integerKeySchema(def.UnderlyingType)
+	default:
+		return nil, false
+	}
+
+	// The keys of an enum type are its values.
+	for _, enum := range def.Enums {
+		schema.Enum = append(schema.Enum, fmt.Sprint(enum.Value))
+	}
+
+	return schema, true
+}
+
+func integerKeySchema(goType string) *spec.Schema {
+
+	schema := new(spec.Schema).Typed("string", "")
+
+	if strings.HasPrefix(goType, "u") || goType == "byte" {
+		schema.WithPattern("^[0-9]+$")
+	} else {
+		schema.WithPattern("^-?[0-9]+$")
+	}
+
+	return schema
+}
+
+func isIntegerKind(goType string) bool {
+
+	switch goType {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"byte", "rune":
+		return true
+	}
+
+	return false
+}
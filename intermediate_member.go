@@ -19,10 +19,13 @@ type MemberIntermediate struct {
 	Description   string
 	Validations   Validator
 	Deprecated    bool
+	Extensions    spec.Extensions
+	Inline        *DefinitionIntermediate // The anonymous struct of the type, if any.
+	Mapping       *spec.Schema            // The schema mapped to the type, if any; see '-types'.
 }
 
 func (this *MemberIntermediate) IsRequired() bool {
-	return this.Validations.IsRequired()
+	return isRequired(this.Validations, this.JsonOmitEmpty)
 }
 
 func (this *MemberIntermediate) GoType() string {
@@ -47,13 +50,7 @@ func (this *MemberIntermediate) DefinitionRef() string {
 
 func (this *MemberIntermediate) SwaggerName() string {
 
-	goType := this.Type
-	goType = strings.TrimPrefix(goType, "*")
-
-	idx := strings.Index(goType, ".")
-	if idx > -1 {
-		goType = goType[idx+1:]
-	}
+	goType := typeDisplayName(this.Type)
 
 	var name string
 
@@ -72,13 +69,7 @@ func (this *MemberIntermediate) SwaggerName() string {
 
 func (this *MemberIntermediate) CanonicalName() string {
 
-	goType := this.Type
-	goType = strings.TrimPrefix(goType, "*")
-
-	idx := strings.Index(goType, ".")
-	if idx > -1 {
-		goType = goType[idx+1:]
-	}
+	goType := typeDisplayName(this.Type)
 
 	name := this.PackagePath + "." + goType
 	name = strings.Replace(name, "/", ".", -1)
@@ -101,8 +92,28 @@ func (this *MemberIntermediate) Schema() *spec.Schema {
 	}
 	schema.Title = name
 	schema.Description = this.Description
+	addExtensions(&schema.VendorExtensible, this.Extensions)
 
-	if isPrimitive, t, f := IsPrimitive(this.Type); isPrimitive {
+	if this.Deprecated {
+		schema.AddExtension("x-deprecated", true)
+	}
+
+	if IsNullable(this.Type) {
+		schema.AddExtension("x-nullable", true)
+	}
+
+	if this.Mapping != nil {
+		title, description := schema.Title, schema.Description
+
+		schema.SchemaProps = this.Mapping.SchemaProps
+		schema.SwaggerSchemaProps = this.Mapping.SwaggerSchemaProps
+		schema.Title = title
+		if description != "" {
+			schema.Description = description
+		}
+
+		addExtensions(&schema.VendorExtensible, this.Mapping.Extensions)
+	} else if isPrimitive, t, f := IsPrimitive(this.Type); isPrimitive {
 
 		schema.Typed(t, f)
 
@@ -162,12 +173,25 @@ func (this *MemberIntermediate) Schema() *spec.Schema {
 			}
 		}
 
+	} else if this.Inline != nil {
+		inline := this.Inline.Schema()
+		schema.Type = inline.Type
+		schema.Required = inline.Required
+		schema.Properties = inline.Properties
+		schema.AllOf = inline.AllOf
 	} else {
 		ref, err := jsonreference.New(this.DefinitionRef())
 		if err != nil {
 			log.Print(errors.Stack(err))
 		}
 		schema.Ref = spec.Ref{Ref: ref}
+
+		// Swagger 2.0 ignores everything beside a reference, so a reference
+		// that's described any further is wrapped.
+		if schema.Title != "" || schema.Description != "" || len(schema.Extensions) > 0 {
+			schema.AllOf = []spec.Schema{{SchemaProps: spec.SchemaProps{Ref: schema.Ref}}}
+			schema.Ref = spec.Ref{}
+		}
 	}
 
 	return schema
@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/go-openapi/spec"
 	"log"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
@@ -12,26 +13,59 @@ import (
 // in the comments. A collection of these can be combined and transformed to
 // create the swagger hierarchy.
 type OperationIntermediate struct {
-	Accepts     []string
-	Description string
-	Method      string
-	PackagePath string // Where this operation was found.
-	Parameters  []ParameterIntermediate
-	Path        string
-	Responses   []*ResponseIntermediate
-	Summary     string
-	Tags        []string
+	Consumes     []string
+	Description  string
+	Examples     []ExampleIntermediate
+	Extensions   spec.Extensions
+	ExternalDocs *ExternalDocsIntermediate
+	Method       string
+	OperationId  string
+	PackagePath  string // Where this operation was found.
+	Parameters   []ParameterIntermediate
+	Path         string
+	Produces     []string
+	Raw          []RawIntermediate
+	Responses    []*ResponseIntermediate
+	Summary      string
+	Tags         []string
 }
 
 type ParameterIntermediate struct {
+	Name        string
 	In          string
 	Required    bool
 	Description string
-	Type        *MemberIntermediate
+	Type        SchemerDefiner
+	MediaTypes  []MediaTypeIntermediate // Only used by the request body.
 }
 
 func (this *ParameterIntermediate) Schema() *spec.Schema {
-	return this.Type.Schema()
+
+	schema := this.Type.Schema()
+
+	if schema != nil && this.In == "body" {
+		schema.Title = ""
+	}
+
+	return schema
+}
+
+// A request body may be described differently for each media type it is
+// consumed as, e.g. a JSON body versus a form body.
+type MediaTypeIntermediate struct {
+	MediaType string
+	Type      SchemerDefiner
+}
+
+func (this *MediaTypeIntermediate) Schema() *spec.Schema {
+
+	schema := this.Type.Schema()
+
+	if schema != nil {
+		schema.Title = ""
+	}
+
+	return schema
 }
 
 type ResponseIntermediate struct {
@@ -54,7 +88,7 @@ func (this *ResponseIntermediate) Schema() *spec.Schema {
 
 // This function does not do type detection. It merely scrapes what information
 // there is in the comment block.
-func intermediatateOperation(commentBlock string) OperationIntermediate {
+func intermediatateOperation(commentBlock CommentBlock) OperationIntermediate {
 
 	/*
 		OpenAPI Summary:
@@ -109,16 +143,50 @@ func intermediatateOperation(commentBlock string) OperationIntermediate {
 
 		OpenAPI Content Type:
 			application/json
+
+		OpenAPI Consumes:
+			application/json
+			multipart/form-data
+
+		OpenAPI Produces:
+			application/json
+			text/csv
+
+		OpenAPI External Docs:
+			https://agame.com/docs/villages#list  Querying villages by area.
+
+		OpenAPI Examples:
+			200 [{"name": "Riverwood", "pop": 3}]
+
+		OpenAPI Extensions:
+			x-rate-limit  100
+
+		OpenAPI Raw:
+			security:
+			  - api_key: []
 	*/
 
+	// Blocks in the @-annotation dialect have no sections.
+	if isAnnotatedOperation(commentBlock.Text) {
+		return intermediatateAnnotatedOperation(commentBlock.Text)
+	}
+
 	var oi OperationIntermediate = OperationIntermediate{
-		Accepts:    make([]string, 0),
+		Consumes:   make([]string, 0),
+		Examples:   make([]ExampleIntermediate, 0),
+		Extensions: make(spec.Extensions),
 		Parameters: make([]ParameterIntermediate, 0),
+		Produces:   make([]string, 0),
+		Raw:        make([]RawIntermediate, 0),
 		Responses:  make([]*ResponseIntermediate, 0),
 		Tags:       make([]string, 0),
 	}
 
-	sections := parseSections(commentBlock)
+	sections := parseSections(commentBlock.Text)
+
+	// Operation and tag blocks may be combined. Once a tag is declared, the
+	// sections that follow belong to the tag.
+	inTag := false
 
 	//log.Print("\n",commentBlock)
 
@@ -144,27 +212,24 @@ func intermediatateOperation(commentBlock string) OperationIntermediate {
 		case "openapi path parameters":
 			oi.Parameters = append(oi.Parameters, parsePathParams(section)...)
 		case "openapi request body":
-			l, ok := section.Line(0)
+			bodyParam, ok := parseRequestBody(section)
 			if !ok {
 				continue
 			}
 
-			bodyType := getFirstWord(l)
-			if bodyType == "nil" {
-				continue
-			}
-
-			bodyParam := ParameterIntermediate{
-				In:          "body",
-				Required:    true,
-				Description: "",
-				Type:        &MemberIntermediate{Type: bodyType},
+			for _, mediaType := range bodyParam.MediaTypes {
+				oi.Consumes = appendMediaTypes(oi.Consumes, mediaType.MediaType)
 			}
 
 			oi.Parameters = append(oi.Parameters, bodyParam)
 
 		case "openapi responses":
-			oi.Responses = parseResponses(section)
+			oi.Responses = append(oi.Responses, parseResponses(section)...)
+		case "openapi response body":
+			// This is shorthand for a single successful response.
+			if response, ok := parseResponseBody(section); ok {
+				oi.Responses = append(oi.Responses, response)
+			}
 		case "openapi description":
 			oi.Description = section.Body
 		case "openapi tags":
@@ -178,18 +243,33 @@ func intermediatateOperation(commentBlock string) OperationIntermediate {
 				}
 			}
 		case "openapi content type":
-			for _, l := range section.Lines() {
-				l = getFirstWord(l)
-				l = strings.ToLower(l)
-
-				if l == "" {
-					continue
-				} else if strings.Contains(l, "json") {
-					l = "application/json"
-				} else if strings.Contains(l, "xml") {
-					l = "application/xml"
-				}
+			// This is shorthand for symmetric consumption and production.
+			mediaTypes := parseMediaTypes(section.Lines())
+			oi.Consumes = appendMediaTypes(oi.Consumes, mediaTypes...)
+			oi.Produces = appendMediaTypes(oi.Produces, mediaTypes...)
+		case "openapi consumes":
+			oi.Consumes = appendMediaTypes(oi.Consumes, parseMediaTypes(section.Lines())...)
+		case "openapi produces":
+			oi.Produces = appendMediaTypes(oi.Produces, parseMediaTypes(section.Lines())...)
+		case "openapi extensions":
+			for name, value := range parseExtensions(section) {
+				oi.Extensions.Add(name, value)
 			}
+		case "openapi raw":
+			if raw, ok := parseRaw(commentBlock, section); ok {
+				oi.Raw = append(oi.Raw, raw)
+			}
+		case "openapi examples":
+			oi.Examples = append(oi.Examples, parseExamples(section)...)
+		case "openapi external docs":
+			if !inTag {
+				oi.ExternalDocs = parseExternalDocs(section)
+			}
+		case "openapi tag":
+			// Tag declarations are handled separately.
+			inTag = true
+		case "openapi tag order":
+			// Tag declarations are handled separately.
 		default:
 			log.Print("Unrecognized section:\n", section)
 		}
@@ -198,6 +278,196 @@ func intermediatateOperation(commentBlock string) OperationIntermediate {
 	return oi
 }
 
+// Media types may be given in full (type/subtype, with optional parameters) or
+// with one of a few shorthand names. Media types may be given one per line or
+// separated by commas.
+func parseMediaTypes(lines []string) []string {
+
+	mediaTypes := make([]string, 0)
+
+	for _, l := range lines {
+		for _, mediaType := range strings.Split(l, ",") {
+			mediaType = strings.TrimSpace(mediaType)
+			mediaType_ := strings.ToLower(mediaType)
+
+			switch {
+			case mediaType == "":
+				continue
+			case strings.Contains(mediaType, "/"):
+				// Anything resembling a full media type is taken as-is.
+			case mediaType_ == "json":
+				mediaType = "application/json"
+			case mediaType_ == "xml":
+				mediaType = "application/xml"
+			case mediaType_ == "text":
+				mediaType = "text/plain"
+			case mediaType_ == "html":
+				mediaType = "text/html"
+			case mediaType_ == "form":
+				mediaType = "application/x-www-form-urlencoded"
+			case mediaType_ == "multipart":
+				mediaType = "multipart/form-data"
+			case mediaType_ == "binary":
+				mediaType = "application/octet-stream"
+			default:
+				log.Print("WARNING: Unrecognized media type: " + mediaType)
+				continue
+			}
+
+			mediaTypes = appendMediaTypes(mediaTypes, mediaType)
+		}
+	}
+
+	return mediaTypes
+}
+
+// This appends the media types that aren't already in the set.
+func appendMediaTypes(set []string, mediaTypes ...string) []string {
+	for _, mediaType := range mediaTypes {
+		if !sContains(set, mediaType) {
+			set = append(set, mediaType)
+		}
+	}
+
+	return set
+}
+
+// The first line of the request body describes the body: its type, whether it
+// is required (the default) or optional, and a description. The remaining lines
+// may name the body parameter, give a type per media type, or continue the
+// description.
+//
+// If the first line starts with a media type, the body is described by media
+// types alone, and the first of them provides the type of the body.
+func parseRequestBody(section Section) (ParameterIntermediate, bool) {
+
+	/*
+		OpenAPI Request Body:
+			types.Village  required  The village to create.
+			name  village
+			application/json                   types.Village
+			application/x-www-form-urlencoded  types.VillageForm
+
+		OpenAPI Request Body:
+			text/csv  []byte  optional  The villages to import, as CSV.
+	*/
+
+	var (
+		bodyParam ParameterIntermediate = ParameterIntermediate{
+			Name:       "body",
+			In:         "body",
+			Required:   true,
+			MediaTypes: make([]MediaTypeIntermediate, 0),
+		}
+		desc []string = make([]string, 0)
+
+		// A media type must have a registered top-level type, so that a
+		// description like 'CSV/TSV uploads are accepted.' isn't taken for one.
+		rxMediaType *regexp.Regexp = regexp.MustCompile(`^(?i:application|audio|font|image|message|model|multipart|text|video|\*)/[\w.+*-]+(;\S+)?$`)
+	)
+
+	lines := section.Lines()
+	if len(lines) == 0 {
+		return bodyParam, false
+	}
+
+	// The necessity and description are shared by both forms of the first line.
+	parseFirstLine := func(words []string) {
+		if len(words) > 0 {
+			switch strings.ToLower(words[0]) {
+			case "required":
+				words = words[1:]
+			case "optional":
+				bodyParam.Required = false
+				words = words[1:]
+			}
+		}
+
+		if len(words) > 0 {
+			desc = append(desc, strings.Join(words, " "))
+		}
+	}
+
+	words := strings.Fields(lines[0])
+	if rxMediaType.MatchString(words[0]) {
+		// A media type must be accompanied by a type.
+		if len(words) < 2 {
+			log.Print("WARNING: Request body media type has no type: " + lines[0])
+			return bodyParam, false
+		}
+
+		bodyParam.MediaTypes = append(bodyParam.MediaTypes, MediaTypeIntermediate{
+			MediaType: words[0],
+			Type:      intermediatateType(words[1]),
+		})
+		bodyParam.Type = intermediatateType(words[1])
+		parseFirstLine(words[2:])
+	} else {
+		if words[0] == "nil" {
+			return bodyParam, false
+		}
+
+		bodyParam.Type = intermediatateType(words[0])
+		parseFirstLine(words[1:])
+	}
+
+	for _, l := range lines[1:] {
+		words := strings.Fields(l)
+
+		switch {
+		case strings.ToLower(words[0]) == "name" && len(words) == 2:
+			bodyParam.Name = words[1]
+		case rxMediaType.MatchString(words[0]) && len(words) <= 2:
+			goType := bodyParam.Type.GoType()
+			if len(words) > 1 {
+				goType = words[1]
+			}
+
+			bodyParam.MediaTypes = append(bodyParam.MediaTypes, MediaTypeIntermediate{
+				MediaType: words[0],
+				Type:      intermediatateType(goType),
+			})
+		default:
+			desc = append(desc, l)
+		}
+	}
+
+	bodyParam.Description = strings.Join(desc, "\n")
+
+	return bodyParam, true
+}
+
+// The first line gives the type of the response body, optionally followed by a
+// description, which may continue on the following lines.
+func parseResponseBody(section Section) (*ResponseIntermediate, bool) {
+
+	/*
+		OpenAPI Response Body:
+			[]types.Village  The villages of the world.
+	*/
+
+	lines := section.Lines()
+	if len(lines) == 0 {
+		return nil, false
+	}
+
+	words := strings.Fields(lines[0])
+	desc := append([]string{strings.Join(words[1:], " ")}, lines[1:]...)
+
+	response := &ResponseIntermediate{
+		Success:     true,
+		StatusCode:  200,
+		Description: strings.TrimSpace(strings.Join(desc, "\n")),
+		Type:        intermediatateType(words[0]),
+	}
+
+	if response.Description == "" {
+		response.Description = http.StatusText(response.StatusCode)
+	}
+
+	return response, true
+}
+
 func parsePathParams(section Section) []ParameterIntermediate {
 	var params []ParameterIntermediate = parseParams(section)
 
@@ -257,6 +527,7 @@ func parseParams(section Section) []ParameterIntermediate {
 		}
 
 		var parameterIntermediate ParameterIntermediate = ParameterIntermediate{
+			Name:        matches[1],
 			Description: matches[4],
 			In:          "", // This should get set by the caller.
 			Required:    strings.ToLower(matches[3]) == "required",
@@ -291,46 +562,7 @@ func parseResponses(section Section) []*ResponseIntermediate {
 			continue
 		}
 
-		goType := matches[2]
-
-		var responseType SchemerDefiner
-
-		if isMap, k, v := IsMap(goType); isMap {
-
-			keyType := &MemberIntermediate{
-				Type:        k,
-				Validations: make(ValidationMap),
-			}
-
-			valueType := &MemberIntermediate{
-				Type:        v,
-				Validations: make(ValidationMap),
-			}
-
-			responseType = &MapIntermediate{
-				Type:        goType,
-				ValueType:   valueType,
-				KeyType:     keyType,
-				Validations: make(ValidationMap),
-			}
-
-		} else if isSlice, v := IsSlice(goType); isSlice {
-			valueType := &MemberIntermediate{
-				Type:        v,
-				Validations: make(ValidationMap),
-			}
-
-			responseType = &SliceIntermediate{
-				Type:        goType,
-				ValueType:   valueType,
-				Validations: make(ValidationMap),
-			}
-		} else {
-			responseType = &MemberIntermediate{
-				Type:        goType,
-				Validations: make(ValidationMap),
-			}
-		}
+		responseType := intermediatateType(matches[2])
 
 		statusCode, _ := strconv.Atoi(matches[1])
 
@@ -346,3 +578,77 @@ func parseResponses(section Section) []*ResponseIntermediate {
 
 	return out
 }
+
+// This creates the intermediate appropriate for a type referenced in a comment
+// block.
+func intermediatateType(goType string) SchemerDefiner {
+
+	if isMap, k, v := IsMap(goType); isMap {
+
+		keyType := &MemberIntermediate{
+			Type:        k,
+			Validations: make(ValidationMap),
+		}
+
+		valueType := intermediatateElementType(v, "", make(ValidationMap), nil)
+
+		return &MapIntermediate{
+			Type:        goType,
+			ValueType:   valueType,
+			KeyType:     keyType,
+			Validations: make(ValidationMap),
+		}
+
+	} else if isSlice, v := IsSlice(goType); isSlice {
+		valueType := intermediatateElementType(v, "", make(ValidationMap), nil)
+
+		return &SliceIntermediate{
+			Type:        goType,
+			ValueType:   valueType,
+			Validations: make(ValidationMap),
+		}
+	}
+
+	return &MemberIntermediate{
+		Type:        goType,
+		Validations: make(ValidationMap),
+	}
+}
+
+// This creates the intermediate of the elements of a collection, which may be
+// collections themselves, e.g. '[]Point' of '[][]Point'. The validations of a
+// field apply to its innermost elements, and an anonymous struct is always the
+// innermost element.
+func intermediatateElementType(goType, name string, validations Validator, inline *DefinitionIntermediate) SchemerDefiner {
+
+	if isMap, k, v := IsMap(goType); isMap {
+		keyType := &MemberIntermediate{
+			Type:        k,
+			Name:        name,
+			Validations: make(ValidationMap),
+		}
+
+		return &MapIntermediate{
+			Name:        name,
+			Type:        goType,
+			ValueType:   intermediatateElementType(v, name, validations, inline),
+			KeyType:     keyType,
+			Validations: make(ValidationMap),
+		}
+
+	} else if isSlice, v := IsSlice(goType); isSlice {
+		return &SliceIntermediate{
+			Name:        name,
+			Type:        goType,
+			ValueType:   intermediatateElementType(v, name, validations, inline),
+			Validations: make(ValidationMap),
+		}
+	}
+
+	return &MemberIntermediate{
+		Type:        goType,
+		Name:        name,
+		Validations: validations,
+		Inline:      inline,
+	}
+}
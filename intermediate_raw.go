@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/go-openapi/swag"
+	"github.com/jackmanlabs/errors"
+	"gopkg.in/yaml.v2"
+	"log"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// A raw fragment is a piece of the Swagger document given verbatim, as JSON or
+// YAML, for anything the sections can't express. It is merged into the
+// generated object after everything else.
+type RawIntermediate struct {
+	Position string // Where the fragment was found, for reporting.
+	Value    map[string]interface{}
+}
+
+// Malformed fragments are reported at their position in the source and
+// skipped.
+func parseRaw(commentBlock CommentBlock, section Section) (RawIntermediate, bool) {
+
+	/*
+		OpenAPI Raw:
+			security:
+			  - api_key: []
+			responses:
+			  429:
+			    description: Too many requests.
+	*/
+
+	raw := RawIntermediate{
+		Position: sectionPosition(commentBlock, section),
+	}
+
+	value, err := parseRawValue(section.Body)
+	if err != nil {
+		log.Printf("ERROR: %s: Malformed raw fragment: %s", raw.Position, err)
+		return raw, false
+	}
+
+	raw.Value = value
+
+	return raw, true
+}
+
+func parseRawValue(body string) (map[string]interface{}, error) {
+
+	var value map[string]interface{}
+
+	err := parseJsonOrYaml(body, &value)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	return value, nil
+}
+
+// This decodes JSON, or YAML when it isn't JSON, into the value.
+func parseJsonOrYaml(body string, value interface{}) error {
+
+	b := []byte(strings.TrimSpace(body))
+
+	if !json.Valid(b) {
+		// YAML doesn't allow tabs for indentation, but Go comments are full of
+		// them.
+		rxTabs := regexp.MustCompile(`(?m)^\t+`)
+		b = rxTabs.ReplaceAllFunc(b, func(tabs []byte) []byte {
+			return []byte(strings.Repeat("  ", len(tabs)))
+		})
+
+		var doc interface{}
+		err := yaml.Unmarshal(b, &doc)
+		if err != nil {
+			return errors.Stack(err)
+		}
+
+		b, err = swag.YAMLToJSON(doc)
+		if err != nil {
+			return errors.Stack(err)
+		}
+	}
+
+	err := json.Unmarshal(b, value)
+	if err != nil {
+		return errors.Stack(err)
+	}
+
+	return nil
+}
+
+// This merges the fragments into the target, which must be a pointer to the
+// object from which the fragments are relative. Fragments are merged in order,
+// with the semantics of a JSON merge patch (RFC 7386): objects are merged
+// recursively, null removes a member, and any other value replaces the
+// original.
+func mergeRaw(target interface{}, fragments []RawIntermediate) error {
+
+	if len(fragments) == 0 {
+		return nil
+	}
+
+	doc, err := marshalDocument(target)
+	if err != nil {
+		return errors.Stack(err)
+	}
+
+	positions := make([]string, 0, len(fragments))
+	for _, fragment := range fragments {
+		doc = mergePatch(doc, fragment.Value)
+		positions = append(positions, fragment.Position)
+	}
+
+	err = unmarshalDocument(doc, target)
+	if err != nil {
+		return errors.Newf("%s: The raw fragments don't fit: %s", strings.Join(positions, ", "), err)
+	}
+
+	return nil
+}
+
+// This gives the generic JSON form of a Swagger object, for merging.
+func marshalDocument(source interface{}) (map[string]interface{}, error) {
+
+	b, err := json.Marshal(source)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	var doc map[string]interface{}
+	err = json.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	return doc, nil
+}
+
+// This replaces the target with the generic JSON form of a Swagger object. The
+// target is reset so that removed members don't linger. If the document
+// doesn't fit, the original is restored.
+func unmarshalDocument(doc map[string]interface{}, target interface{}) error {
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return errors.Stack(err)
+	}
+
+	v := reflect.ValueOf(target).Elem()
+	original := reflect.New(v.Type()).Elem()
+	original.Set(v)
+	v.Set(reflect.Zero(v.Type()))
+
+	err = json.Unmarshal(b, target)
+	if err != nil {
+		v.Set(original)
+		return err
+	}
+
+	return nil
+}
+
+func mergePatch(dst, patch map[string]interface{}) map[string]interface{} {
+
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+
+	for k, v := range patch {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+
+		vMap, vOk := v.(map[string]interface{})
+		dMap, dOk := dst[k].(map[string]interface{})
+		if vOk && dOk {
+			dst[k] = mergePatch(dMap, vMap)
+		} else if vOk {
+			// Nulls in a new object have nothing to remove.
+			dst[k] = mergePatch(nil, vMap)
+		} else {
+			dst[k] = v
+		}
+	}
+
+	return dst
+}
@@ -3,23 +3,25 @@ package main
 import (
 	"github.com/go-openapi/spec"
 	"strconv"
+	"strings"
 )
 
 type SliceIntermediate struct {
-	Name          string // Name in Go struct.
-	Type          string // Go type
-	JsonName      string // JSON name.
-	JsonOmitEmpty bool   // If the omitempty flag was given in the JSON.
-	ValueType     *MemberIntermediate
+	Name          string         // Name in Go struct.
+	Type          string         // Go type
+	JsonName      string         // JSON name.
+	JsonOmitEmpty bool           // If the omitempty flag was given in the JSON.
+	ValueType     SchemerDefiner // Possibly a collection itself.
 	Description   string
 	Validations   Validator
 	Deprecated    bool
+	Extensions    spec.Extensions
 	PackageName   string // Necessary for canonical and swagger names.
 	PackagePath   string
 }
 
 func (this *SliceIntermediate) IsRequired() bool {
-	return this.Validations.IsRequired()
+	return isRequired(this.Validations, this.JsonOmitEmpty)
 }
 
 func (this *SliceIntermediate) GoType() string {
@@ -49,12 +51,32 @@ func (this *SliceIntermediate) Schema() *spec.Schema {
 	schema.Title = name
 
 	schema.Description = this.Description
+	addExtensions(&schema.VendorExtensible, this.Extensions)
+
+	if this.Deprecated {
+		schema.AddExtension("x-deprecated", true)
+	}
+
+	if IsNullable(this.Type) {
+		schema.AddExtension("x-nullable", true)
+	}
 	schema.Items = new(spec.SchemaOrArray)
 
 	schema.Typed("array", "")
 
 	schema.Items.Schema = this.ValueType.Schema()
 
+	// Only a slice of bytes is written as a base64 string; the bytes of an
+	// array, e.g. '[4]byte', are written as numbers.
+	if valueType := strings.TrimLeft(this.ValueType.GoType(), "*"); valueType == "byte" || valueType == "uint8" {
+		schema.Items.Schema.Typed("integer", "uint8")
+	}
+
+	if length := ArrayLength(this.Type); length >= 0 {
+		schema.WithMinItems(length)
+		schema.WithMaxItems(length)
+	}
+
 	if this.Validations.Min() >= 0 {
 		schema.WithMinItems(int64(this.Validations.Min()))
 	}
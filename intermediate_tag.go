@@ -1,43 +1,179 @@
 package main
 
-import "strings"
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
 
 type TagIntermediate struct {
-	Name        string
-	Description string
+	Name         string
+	Description  string
+	ExternalDocs *ExternalDocsIntermediate
+	Order        int  // Only meaningful if Ordered is true.
+	Ordered      bool // If an explicit order was given.
 }
 
+// Tag blocks may declare any number of tags. The sections following an
+// 'OpenAPI Tag:' section (external docs, order) apply to that tag.
 func intermediatateTags(commentBlock string) []TagIntermediate {
 
-	tagIntermediates := make([]TagIntermediate, 0)
+	/*
+		OpenAPI Tag:
+			Villages
+			Villages are the basic unit of settlement in the game.
+		OpenAPI Tag Order:
+			1
+		OpenAPI External Docs:
+			https://agame.com/docs/villages  Everything about villages.
+	*/
+
+	var (
+		tagIntermediates []TagIntermediate = make([]TagIntermediate, 0)
+		current          *TagIntermediate  // Leave nil until a tag is identified.
+	)
+
 	sections := parseSections(commentBlock)
 
 	for _, section := range sections {
 
-		var tagIntermediate TagIntermediate
+		title := strings.TrimSpace(section.Title)
+		title = strings.ToLower(title)
 
-		title := strings.ToLower(section.Title)
-		if title != "openapi tag" {
-			continue
+		switch title {
+		case "openapi tag":
+			if current != nil {
+				tagIntermediates = append(tagIntermediates, *current)
+			}
+
+			lines := section.Lines()
+			if len(lines) == 0 {
+				// No body means no tag.
+				current = nil
+				continue
+			}
+
+			current = new(TagIntermediate)
+
+			// The first line of the body is the actual tag.
+			current.Name = lines[0]
+
+			// The remaining lines are the tag description.
+			if len(lines) > 1 {
+				idx := strings.Index(section.Body, lines[0]) + len(lines[0])
+				current.Description = strings.TrimSpace(section.Body[idx:])
+			}
+		case "openapi tag order":
+			if current == nil {
+				continue
+			}
+
+			l, ok := section.Line(0)
+			if !ok {
+				continue
+			}
+
+			order, err := strconv.Atoi(getFirstWord(l))
+			if err != nil {
+				log.Printf("WARNING: Tag order for '%s' is not an integer: %s", current.Name, l)
+				continue
+			}
+
+			current.Order = order
+			current.Ordered = true
+		case "openapi external docs":
+			if current == nil {
+				continue
+			}
+
+			current.ExternalDocs = parseExternalDocs(section)
 		}
+	}
+
+	// capture the last tag.
+	if current != nil {
+		tagIntermediates = append(tagIntermediates, *current)
+	}
+
+	return tagIntermediates
+}
+
+// This combines duplicate tag declarations, adds the tags that are used by
+// operations but never declared, warns about declared tags that no operation
+// uses, and sorts the result.
+//
+// Tags with an explicit order come first (ascending), followed by the
+// remaining tags in alphabetical order.
+func reconcileTags(tagIntermediates []TagIntermediate, operationIntermediates []OperationIntermediate) []TagIntermediate {
+
+	var (
+		tags  map[string]*TagIntermediate = make(map[string]*TagIntermediate)
+		names []string                    = make([]string, 0)
+	)
 
-		lines := strings.Split(section.Body, "\n")
-		if len(lines) == 0 {
-			// No body means no tag.
+	for i := range tagIntermediates {
+		tagIntermediate := tagIntermediates[i]
+
+		existing, ok := tags[tagIntermediate.Name]
+		if !ok {
+			tags[tagIntermediate.Name] = &tagIntermediate
+			names = append(names, tagIntermediate.Name)
 			continue
 		}
 
-		// The first line of the body is the actual tag.
-		tagIntermediate.Name = strings.TrimSpace(lines[0])
+		log.Print("WARNING: Tag declared more than once: " + tagIntermediate.Name)
+
+		if existing.Description == "" {
+			existing.Description = tagIntermediate.Description
+		}
 
-		// The remaining lines are the tag description.
-		if len(lines) > 1 {
-			tagIntermediate.Description = strings.Join(lines[1:], "\n")
+		if existing.ExternalDocs == nil {
+			existing.ExternalDocs = tagIntermediate.ExternalDocs
 		}
 
-		tagIntermediates = append(tagIntermediates, tagIntermediate)
+		if !existing.Ordered && tagIntermediate.Ordered {
+			existing.Order = tagIntermediate.Order
+			existing.Ordered = true
+		}
 	}
 
-	return tagIntermediates
+	used := make(map[string]bool)
+	for _, operationIntermediate := range operationIntermediates {
+		for _, name := range operationIntermediate.Tags {
+			used[name] = true
+
+			if _, ok := tags[name]; !ok {
+				tags[name] = &TagIntermediate{Name: name}
+				names = append(names, name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if !used[name] {
+			log.Print("WARNING: Tag declared but not used by any operation: " + name)
+		}
+	}
+
+	sort.SliceStable(names, func(i, j int) bool {
+		a, b := tags[names[i]], tags[names[j]]
+
+		if a.Ordered != b.Ordered {
+			return a.Ordered
+		}
+
+		if a.Ordered && a.Order != b.Order {
+			return a.Order < b.Order
+		}
+
+		return a.Name < b.Name
+	})
+
+	out := make([]TagIntermediate, 0, len(names))
+	for _, name := range names {
+		out = append(out, *tags[name])
+	}
 
+	return out
 }
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/jackmanlabs/errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A JSON Patch (RFC 6902) is a list of operations applied to the document in
+// order. Every operation must succeed; a path that no longer matches the
+// document is an error rather than a silent no-op.
+type JsonPatch struct {
+	Source     string // The file the patch was loaded from, for reporting.
+	Operations []JsonPatchOperation
+}
+
+type JsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+func (this *JsonPatch) Transform(doc interface{}) (interface{}, error) {
+
+	var err error
+
+	for i := range this.Operations {
+		operation := &this.Operations[i]
+
+		doc, err = operation.apply(doc)
+		if err != nil {
+			return nil, errors.Newf("%s: operation %d (%s %s): %s", this.Source, i, operation.Op, operation.Path, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func (this *JsonPatchOperation) apply(doc interface{}) (interface{}, error) {
+
+	path, err := parsePointer(this.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch this.Op {
+	case "add":
+		return pointerAdd(doc, path, this.Value)
+	case "remove":
+		return pointerRemove(doc, path)
+	case "replace":
+		if _, err := pointerGet(doc, path); err != nil {
+			return nil, err
+		}
+		return pointerReplace(doc, path, this.Value)
+	case "test":
+		value, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, this.Value) {
+			return nil, errors.New("The value doesn't match.")
+		}
+		return doc, nil
+	case "move", "copy":
+		from, err := parsePointer(this.From)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, errors.Newf("from %s: %s", this.From, err)
+		}
+
+		if this.Op == "move" {
+			if strings.HasPrefix(this.Path+"/", this.From+"/") && this.Path != this.From {
+				return nil, errors.New("A value can't be moved into itself.")
+			}
+
+			doc, err = pointerRemove(doc, from)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			value, err = deepCopyJson(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return pointerAdd(doc, path, value)
+	}
+
+	return nil, errors.Newf("Unrecognized operation: '%s'", this.Op)
+}
+
+// JSON pointers (RFC 6901) are parsed into their unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.Newf("Invalid JSON pointer: '%s'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.Replace(token, "~1", "/", -1)
+		token = strings.Replace(token, "~0", "~", -1)
+		tokens[i] = token
+	}
+
+	return tokens, nil
+}
+
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+
+	for i, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, errors.Newf("Path not found: '%s' doesn't exist", formatPointer(path[:i+1]))
+			}
+			doc = value
+		case []interface{}:
+			index, err := pointerIndex(node, token, false)
+			if err != nil {
+				return nil, errors.Newf("Path not found: %s: %s", formatPointer(path[:i+1]), err)
+			}
+			doc = node[index]
+		default:
+			return nil, errors.Newf("Path not found: '%s' is neither an object nor an array", formatPointer(path[:i]))
+		}
+	}
+
+	return doc, nil
+}
+
+// The change is made to the container found at the parent of the path. As
+// arrays may be reallocated, every container on the path is rebuilt on the way
+// back up.
+func pointerUpdate(doc interface{}, path []string, change func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+
+	// The parent is looked up first so that a missing path is reported in
+	// full.
+	if _, err := pointerGet(doc, path[:len(path)-1]); err != nil {
+		return nil, err
+	}
+
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+
+	child, err := pointerGet(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+
+	child, err = pointerUpdate(child, path[1:], change)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		node[path[0]] = child
+	case []interface{}:
+		index, _ := pointerIndex(node, path[0], false)
+		node[index] = child
+	}
+
+	return doc, nil
+}
+
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return pointerUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index, err := pointerIndex(node, token, true)
+			if err != nil {
+				return nil, errors.Newf("Path not found: %s: %s", formatPointer(path), err)
+			}
+
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+
+		return nil, errors.Newf("Path not found: '%s' is neither an object nor an array", formatPointer(path[:len(path)-1]))
+	})
+}
+
+func pointerRemove(doc interface{}, path []string) (interface{}, error) {
+
+	if len(path) == 0 {
+		return nil, errors.New("The whole document can't be removed.")
+	}
+
+	return pointerUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			if _, ok := node[token]; !ok {
+				return nil, errors.Newf("Path not found: '%s' doesn't exist", formatPointer(path))
+			}
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			index, err := pointerIndex(node, token, false)
+			if err != nil {
+				return nil, errors.Newf("Path not found: %s: %s", formatPointer(path), err)
+			}
+			return append(node[:index], node[index+1:]...), nil
+		}
+
+		return nil, errors.Newf("Path not found: '%s' is neither an object nor an array", formatPointer(path[:len(path)-1]))
+	})
+}
+
+func pointerReplace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return pointerUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch node := container.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			index, _ := pointerIndex(node, token, false)
+			node[index] = value
+			return node, nil
+		}
+
+		return container, nil
+	})
+}
+
+// When adding, the index may equal the length of the array, or be '-', to
+// append.
+func pointerIndex(array []interface{}, token string, adding bool) (int, error) {
+
+	if token == "-" && adding {
+		return len(array), nil
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, errors.Newf("'%s' is not an array index", token)
+	}
+
+	if index > len(array) || (index == len(array) && !adding) {
+		return 0, errors.Newf("index %d is out of range; the array has %d elements", index, len(array))
+	}
+
+	return index, nil
+}
+
+func formatPointer(path []string) string {
+
+	pointer := ""
+	for _, token := range path {
+		pointer += "/" + escapePointer(token)
+	}
+
+	return pointer
+}
+
+func deepCopyJson(value interface{}) (interface{}, error) {
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	var out interface{}
+	err = json.Unmarshal(b, &out)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	return out, nil
+}
@@ -18,6 +18,14 @@ var (
 	profilePath *string = flag.String("profile", "", "The path where you'd like to store profiling results.")
 	ignore      *string = flag.String("ignore", "", "The comma seperated package paths that you want to ignore.")
 	naming      *string = flag.String("naming", "full", "One of 'full', 'partial', or 'simple' to describe the amount of the package path on the resulting JSON models.")
+	host        *string = flag.String("host", "", "The host (name or IP, with optional port) serving the API. Overrides 'OpenAPI Host:'.")
+	schemes     *string = flag.String("schemes", "", "The comma separated transfer protocols of the API. Overrides 'OpenAPI Schemes:'.")
+	basePath    *string = flag.String("base", "", "The path of a hand-written Swagger document (JSON or YAML) into which the generated document is merged.")
+	precedence  *string = flag.String("precedence", "generated", "One of 'generated' or 'base' to describe which document wins when both define the same member.")
+	required    *string = flag.String("required", "validate", "One of 'validate' or 'json' to describe which struct fields are required.")
+	typesPath   *string = flag.String("types", "", "The path of a file (JSON or YAML) mapping fully qualified Go types to schemas.")
+	embedding   *string = flag.String("embedding", "flatten", "One of 'flatten' or 'allOf' to describe how embedded structs are represented.")
+	overlays    *string = flag.String("overlay", "", "The comma separated paths of OpenAPI Overlays or JSON Patches (JSON or YAML) to apply to the generated document, in order.")
 )
 
 var (
@@ -28,7 +36,8 @@ var (
 	// avoid modifying maps during iterations.
 	pkgInfos        map[string]PackageInfo = make(map[string]PackageInfo)
 	srcPath         string
-	ignoredPackages []string = make([]string, 0)
+	ignoredPackages []string                = make([]string, 0)
+	typeMappings    map[string]*spec.Schema = make(map[string]*spec.Schema) // map[importPath.Type]schema
 )
 
 func main() {
@@ -53,6 +62,57 @@ func main() {
 		log.Fatal("Unrecognized value provided for naming convention: " + *naming)
 	}
 
+	if !(*precedence == "generated" || *precedence == "base") {
+		flag.Usage()
+		log.Fatal("Unrecognized value provided for precedence: " + *precedence)
+	}
+
+	if !(*required == "validate" || *required == "json") {
+		flag.Usage()
+		log.Fatal("Unrecognized value provided for required: " + *required)
+	}
+
+	if !(*embedding == "flatten" || *embedding == "allOf") {
+		flag.Usage()
+		log.Fatal("Unrecognized value provided for embedding: " + *embedding)
+	}
+
+	// Fail early rather than after all of the work is done.
+	var base map[string]interface{}
+	if *basePath != "" {
+		var err error
+		base, err = loadBase(*basePath)
+		if err != nil {
+			log.Fatal(errors.Stack(err))
+		}
+	}
+
+	typeMappings = builtinTypeMappings()
+	if *typesPath != "" {
+		mappings, err := loadTypeMappings(*typesPath)
+		if err != nil {
+			log.Fatal(errors.Stack(err))
+		}
+
+		for goType, schema := range mappings {
+			typeMappings[goType] = schema
+		}
+	}
+
+	transformers := make([]DocumentTransformer, 0)
+	for _, path := range strings.Split(*overlays, ",") {
+		if path == "" {
+			continue
+		}
+
+		transformer, err := loadTransformer(path)
+		if err != nil {
+			log.Fatal(errors.Stack(err))
+		}
+
+		transformers = append(transformers, transformer)
+	}
+
 	ignores := strings.Split(*ignore, ",")
 	for _, i := range ignores {
 		if i != "" {
@@ -76,7 +136,7 @@ func main() {
 
 	// What comments need to be parsed?
 	// Find all comments that could conceivably have our tags in them.
-	packageCommentBlocks := make(map[string][]string, 0)
+	packageCommentBlocks := make(map[string][]CommentBlock, 0)
 	for importPath := range pkgInfos {
 		//log.Print("Scanning package for comments: ", importPath)
 		newBlocks, err := getCommentBlocks(importPath)
@@ -89,9 +149,9 @@ func main() {
 
 	// Now, let's check all of the comment blocks we found for tags, parsing them as necessary.
 	var (
-		apiCommentBlocks       []string            = make([]string, 0)
-		operationCommentBlocks map[string][]string = make(map[string][]string, 0)
-		tagCommentBlocks       []string            = make([]string, 0)
+		apiCommentBlocks       []CommentBlock            = make([]CommentBlock, 0)
+		operationCommentBlocks map[string][]CommentBlock = make(map[string][]CommentBlock, 0)
+		tagCommentBlocks       []CommentBlock            = make([]CommentBlock, 0)
 	)
 
 	for importPath, commentBlocks := range packageCommentBlocks {
@@ -105,7 +165,7 @@ func main() {
 		// We need to know the package so we know where to look for the types.
 		operationCommentBlocks[importPath] = newOperationCommentBlocks
 
-		newTagCommentBlocks := detectOperationComments(commentBlocks)
+		newTagCommentBlocks := detectTagComments(commentBlocks)
 		tagCommentBlocks = append(tagCommentBlocks, newTagCommentBlocks...)
 	}
 
@@ -120,6 +180,16 @@ func main() {
 	// This function takes all API comment blocks, as they should all condense into a single API description.
 	apiIntermediate = intermediatateApi(apiCommentBlocks)
 
+	// The command-line takes precedence so that the same code base can produce
+	// a spec per environment.
+	if *host != "" {
+		apiIntermediate.Host = *host
+	}
+
+	if *schemes != "" {
+		apiIntermediate.Schemes = parseSchemes([]string{*schemes})
+	}
+
 	for importPath, commentBlocks := range operationCommentBlocks {
 		for _, commentBlock := range commentBlocks {
 
@@ -134,11 +204,17 @@ func main() {
 		}
 	}
 
+	// Examples may reference variables in the packages of the operations.
+	resolveOperationExamples(operationIntermediates)
+
 	for _, commentBlock := range tagCommentBlocks {
-		newTagIntermediates := intermediatateTags(commentBlock)
+		newTagIntermediates := intermediatateTags(commentBlock.Text)
 		tagIntermediates = append(tagIntermediates, newTagIntermediates...)
 	}
 
+	// Operations may use tags that were never declared, and vice versa.
+	tagIntermediates = reconcileTags(tagIntermediates, operationIntermediates)
+
 	// I really don't like the way this is done.
 	// TODO: Make this more functional.
 	defStore, err := deriveDefinitionsFromOperations(operationIntermediates)
@@ -146,14 +222,43 @@ func main() {
 		log.Fatal(errors.Stack(err))
 	}
 
+	resolveDefinitionExamples(defStore)
+
 	// Transform the extractions above and combine them into a single Swagger Spec.
 
 	var swagger *spec.Swagger = swaggerizeApi(apiIntermediate)
 
-	swagger.Paths = swaggerizeOperations(operationIntermediates)
+	swagger.Paths = swaggerizeOperations(operationIntermediates, defStore)
 	swagger.Tags = swaggerizeTags(tagIntermediates)
 	swagger.Definitions = swaggerizeDefinitions(defStore)
 
+	// Examples can only be checked once the definitions are known.
+	validateExamples(swagger)
+
+	// Hand-written paths, definitions, etc. are kept alongside the generated
+	// ones. They aren't checked like the generated examples.
+	if base != nil {
+		err = mergeBase(swagger, base, *precedence == "base")
+		if err != nil {
+			log.Fatal(errors.Stack(err))
+		}
+	}
+
+	// The raw fragments take precedence over everything else.
+	err = mergeRaw(swagger, apiIntermediate.Raw)
+	if err != nil {
+		log.Print("ERROR: ", err)
+	}
+
+	// Post-processing comes last, as it's written against the finished
+	// document.
+	if len(transformers) > 0 {
+		err = transformDocument(swagger, transformers)
+		if err != nil {
+			log.Fatal(errors.Stack(err))
+		}
+	}
+
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "\t")
 	err = enc.Encode(swagger)
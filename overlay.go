@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/go-openapi/spec"
+	"github.com/jackmanlabs/errors"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Post-processing documents, either OpenAPI Overlays or JSON Patches, transform
+// the finished document, in the generic form given by marshalDocument.
+type DocumentTransformer interface {
+	Transform(doc interface{}) (interface{}, error)
+}
+
+// The kind of document is detected from its content: a JSON Patch is an array
+// of operations, whereas an Overlay is an object with an 'overlay' member.
+func loadTransformer(path string) (DocumentTransformer, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	var doc interface{}
+	err = parseJsonOrYaml(string(b), &doc)
+	if err != nil {
+		return nil, errors.Newf("%s: %s", path, err)
+	}
+
+	// The generic form is decoded again into the structure of the document.
+	b, err = json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	switch t := doc.(type) {
+	case []interface{}:
+		patch := &JsonPatch{Source: path}
+		err = json.Unmarshal(b, &patch.Operations)
+		if err != nil {
+			return nil, errors.Newf("%s: Malformed JSON Patch: %s", path, err)
+		}
+
+		return patch, nil
+	case map[string]interface{}:
+		if _, ok := t["overlay"]; !ok {
+			break
+		}
+
+		overlay := &Overlay{Source: path}
+		err = json.Unmarshal(b, overlay)
+		if err != nil {
+			return nil, errors.Newf("%s: Malformed Overlay: %s", path, err)
+		}
+
+		// Targets are checked up front so that mistakes are reported before
+		// the work is done.
+		for i, action := range overlay.Actions {
+			_, err = parseJsonPath(action.Target)
+			if err != nil {
+				return nil, errors.Newf("%s: action %d: %s", path, i, err)
+			}
+		}
+
+		return overlay, nil
+	}
+
+	return nil, errors.Newf("%s: Neither an OpenAPI Overlay nor a JSON Patch.", path)
+}
+
+func transformDocument(swagger *spec.Swagger, transformers []DocumentTransformer) error {
+
+	doc, err := marshalDocument(swagger)
+	if err != nil {
+		return errors.Stack(err)
+	}
+
+	var transformed interface{} = doc
+	for _, transformer := range transformers {
+		transformed, err = transformer.Transform(transformed)
+		if err != nil {
+			return errors.Stack(err)
+		}
+	}
+
+	doc, ok := transformed.(map[string]interface{})
+	if !ok {
+		return errors.New("The transformed document is not an object.")
+	}
+
+	err = unmarshalDocument(doc, swagger)
+	if err != nil {
+		return errors.Newf("The transformed document is not a valid Swagger document: %s", err)
+	}
+
+	return nil
+}
+
+// An OpenAPI Overlay (1.0) is a list of actions, each of which updates or
+// removes the nodes selected by its target, a JSONPath expression. A target
+// that selects nothing is an error, as it usually means the generated document
+// has changed under the overlay.
+type Overlay struct {
+	Source  string          `json:"-"` // The file the overlay was loaded from, for reporting.
+	Overlay string          `json:"overlay"`
+	Actions []OverlayAction `json:"actions"`
+}
+
+type OverlayAction struct {
+	Target      string      `json:"target"`
+	Description string      `json:"description"`
+	Update      interface{} `json:"update"`
+	Remove      bool        `json:"remove"`
+}
+
+// Removed array elements are replaced by this marker, then pruned, so that the
+// indexes of the other selected nodes stay valid while the action is applied.
+var overlayRemoved *struct{} = new(struct{})
+
+func (this *Overlay) Transform(doc interface{}) (interface{}, error) {
+
+	for i, action := range this.Actions {
+
+		segments, err := parseJsonPath(action.Target)
+		if err != nil {
+			return nil, errors.Newf("%s: action %d: %s", this.Source, i, err)
+		}
+
+		root := &jsonPathNode{Value: doc}
+		nodes := selectJsonPath([]*jsonPathNode{root}, segments)
+		if len(nodes) == 0 {
+			return nil, errors.Newf("%s: action %d: The target '%s' matches nothing in the document.", this.Source, i, action.Target)
+		}
+
+		for _, node := range nodes {
+			if action.Remove {
+				if node == root {
+					return nil, errors.Newf("%s: action %d: The whole document can't be removed.", this.Source, i)
+				}
+				node.Set(overlayRemoved)
+				continue
+			}
+
+			if action.Update != nil {
+				update, err := deepCopyJson(action.Update)
+				if err != nil {
+					return nil, errors.Stack(err)
+				}
+				node.Set(overlayUpdate(node.Value, update))
+			}
+		}
+
+		doc = pruneRemoved(root.Value)
+	}
+
+	return doc, nil
+}
+
+// Objects are merged recursively, arrays are appended to, and anything else is
+// replaced.
+func overlayUpdate(target, update interface{}) interface{} {
+
+	switch t := target.(type) {
+	case map[string]interface{}:
+		u, ok := update.(map[string]interface{})
+		if !ok {
+			return update
+		}
+
+		for key, value := range u {
+			if existing, ok := t[key]; ok {
+				if _, isArray := existing.([]interface{}); isArray {
+					if values, ok := value.([]interface{}); ok {
+						t[key] = append(existing.([]interface{}), values...)
+						continue
+					}
+				}
+				t[key] = overlayUpdate(existing, value)
+			} else {
+				t[key] = value
+			}
+		}
+
+		return t
+	case []interface{}:
+		return append(t, update)
+	}
+
+	return update
+}
+
+func pruneRemoved(value interface{}) interface{} {
+
+	switch t := value.(type) {
+	case map[string]interface{}:
+		for key, member := range t {
+			if member == overlayRemoved {
+				delete(t, key)
+			} else {
+				t[key] = pruneRemoved(member)
+			}
+		}
+	case []interface{}:
+		out := make([]interface{}, 0, len(t))
+		for _, element := range t {
+			if element != overlayRemoved {
+				out = append(out, pruneRemoved(element))
+			}
+		}
+		return out
+	}
+
+	return value
+}
+
+// A node selected by a JSONPath expression knows where it was found, so that it
+// may be replaced.
+type jsonPathNode struct {
+	Value  interface{}
+	Parent *jsonPathNode
+	Key    string
+	Index  int
+}
+
+func (this *jsonPathNode) Set(value interface{}) {
+
+	this.Value = value
+
+	if this.Parent == nil {
+		return
+	}
+
+	switch parent := this.Parent.Value.(type) {
+	case map[string]interface{}:
+		parent[this.Key] = value
+	case []interface{}:
+		parent[this.Index] = value
+	}
+}
+
+/*
+Only the subset of JSONPath (RFC 9535) needed to target the members of an
+OpenAPI document is supported:
+
+	$.info.title                  member names
+	$.paths['/villages'].get      quoted member names
+	$.paths.*.get                 wildcards
+	$.tags[0]                     array indexes
+	$.tags[?(@.name == 'Legacy')] filters comparing a member to a literal
+	$.tags[?(@.x-internal)]       filters testing for a member
+*/
+type jsonPathSegment struct {
+	Wildcard bool
+	Name     *string
+	Index    *int
+	Filter   *jsonPathFilter
+}
+
+type jsonPathFilter struct {
+	Path     []string    // Member names, relative to '@'.
+	Operator string      // Empty to test for existence.
+	Value    interface{} // A JSON literal.
+}
+
+var (
+	rxJsonPathName   *regexp.Regexp = regexp.MustCompile(`^\.([^.\[\]]+)`)
+	rxJsonPathQuoted *regexp.Regexp = regexp.MustCompile(`^\[\s*('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*")\s*\]`)
+	rxJsonPathIndex  *regexp.Regexp = regexp.MustCompile(`^\[\s*(-?\d+|\*)\s*\]`)
+	rxJsonPathFilter *regexp.Regexp = regexp.MustCompile(`^\[\s*\?\s*\(?\s*@((?:\.[\w-]+)+)\s*(?:(==|!=)\s*('(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|[^\s)\]]+))?\s*\)?\s*\]`)
+)
+
+func parseJsonPath(expression string) ([]jsonPathSegment, error) {
+
+	segments := make([]jsonPathSegment, 0)
+
+	rest := strings.TrimSpace(expression)
+	if !strings.HasPrefix(rest, "$") {
+		return nil, errors.Newf("The target '%s' must begin with '$'.", expression)
+	}
+	rest = rest[1:]
+
+	for rest != "" {
+		var segment jsonPathSegment
+
+		if strings.HasPrefix(rest, "..") {
+			return nil, errors.Newf("The target '%s' uses recursive descent, which isn't supported.", expression)
+		} else if strings.HasPrefix(rest, ".*") {
+			segment.Wildcard = true
+			rest = rest[2:]
+		} else if matches := rxJsonPathName.FindStringSubmatch(rest); matches != nil {
+			name := matches[1]
+			segment.Name = &name
+			rest = rest[len(matches[0]):]
+		} else if matches := rxJsonPathQuoted.FindStringSubmatch(rest); matches != nil {
+			name, err := unquoteJsonPath(matches[1])
+			if err != nil {
+				return nil, errors.Newf("The target '%s' has a malformed name: %s", expression, matches[1])
+			}
+			segment.Name = &name
+			rest = rest[len(matches[0]):]
+		} else if matches := rxJsonPathIndex.FindStringSubmatch(rest); matches != nil {
+			if matches[1] == "*" {
+				segment.Wildcard = true
+			} else {
+				index, _ := strconv.Atoi(matches[1])
+				segment.Index = &index
+			}
+			rest = rest[len(matches[0]):]
+		} else if matches := rxJsonPathFilter.FindStringSubmatch(rest); matches != nil {
+			filter := &jsonPathFilter{
+				Path:     strings.Split(matches[1][1:], "."),
+				Operator: matches[2],
+			}
+
+			if filter.Operator != "" {
+				literal := matches[3]
+				if strings.HasPrefix(literal, "'") {
+					s, err := unquoteJsonPath(literal)
+					if err != nil {
+						return nil, errors.Newf("The target '%s' has a malformed literal: %s", expression, literal)
+					}
+					filter.Value = s
+				} else if err := json.Unmarshal([]byte(literal), &filter.Value); err != nil {
+					return nil, errors.Newf("The target '%s' has a malformed literal: %s", expression, literal)
+				}
+			}
+
+			segment.Filter = filter
+			rest = rest[len(matches[0]):]
+		} else {
+			return nil, errors.Newf("The target '%s' can't be parsed at '%s'.", expression, rest)
+		}
+
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
+
+// Single-quoted strings are converted to double-quoted strings to be decoded
+// as JSON.
+func unquoteJsonPath(quoted string) (string, error) {
+
+	if strings.HasPrefix(quoted, "'") {
+		inner := quoted[1 : len(quoted)-1]
+		inner = strings.Replace(inner, `\'`, `'`, -1)
+		inner = strings.Replace(inner, `"`, `\"`, -1)
+		quoted = `"` + inner + `"`
+	}
+
+	var s string
+	err := json.Unmarshal([]byte(quoted), &s)
+
+	return s, err
+}
+
+func selectJsonPath(nodes []*jsonPathNode, segments []jsonPathSegment) []*jsonPathNode {
+
+	for _, segment := range segments {
+		selected := make([]*jsonPathNode, 0)
+
+		for _, node := range nodes {
+			for _, child := range jsonPathChildren(node) {
+				if segment.matches(child) {
+					selected = append(selected, child)
+				}
+			}
+		}
+
+		nodes = selected
+	}
+
+	return nodes
+}
+
+// The children of an object are visited in the order of their names, so that
+// the result doesn't depend on the order of map iteration.
+func jsonPathChildren(node *jsonPathNode) []*jsonPathNode {
+
+	children := make([]*jsonPathNode, 0)
+
+	switch t := node.Value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(t) {
+			children = append(children, &jsonPathNode{Value: t[key], Parent: node, Key: key})
+		}
+	case []interface{}:
+		for i, element := range t {
+			children = append(children, &jsonPathNode{Value: element, Parent: node, Index: i})
+		}
+	}
+
+	return children
+}
+
+func (this *jsonPathSegment) matches(node *jsonPathNode) bool {
+
+	_, inArray := node.Parent.Value.([]interface{})
+
+	switch {
+	case this.Wildcard:
+		return true
+	case this.Name != nil:
+		return !inArray && node.Key == *this.Name
+	case this.Index != nil:
+		if !inArray {
+			return false
+		}
+
+		index := *this.Index
+		if index < 0 {
+			index += len(node.Parent.Value.([]interface{}))
+		}
+
+		return node.Index == index
+	case this.Filter != nil:
+		value := node.Value
+		for _, name := range this.Filter.Path {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return false
+			}
+
+			value, ok = object[name]
+			if !ok {
+				return false
+			}
+		}
+
+		switch this.Filter.Operator {
+		case "==":
+			return reflect.DeepEqual(value, this.Filter.Value)
+		case "!=":
+			return !reflect.DeepEqual(value, this.Filter.Value)
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -10,6 +10,7 @@ import (
 type Section struct {
 	Title string
 	Body  string
+	Index int // The number of preceding sections in the block with the same title.
 }
 
 // This method returns the line in the body denoted by 'i'.
@@ -55,15 +56,17 @@ func (this Section) String() string {
 func parseSections(commentBlock string) []Section {
 
 	var (
-		sections []Section     = make([]Section, 0)
-		section  *Section      // Leave nil until a new section is identified.
-		body     *bytes.Buffer = bytes.NewBuffer(nil)
+		sections []Section      = make([]Section, 0)
+		section  *Section       // Leave nil until a new section is identified.
+		body     *bytes.Buffer  = bytes.NewBuffer(nil)
+		titles   map[string]int = make(map[string]int) // map[title]occurrences
+		indented bool                                  // Raw sections keep their indentation.
 	)
 
 	scnr := bufio.NewScanner(strings.NewReader(commentBlock))
 	for scnr.Scan() {
-		line := scnr.Text()
-		line = strings.TrimSpace(line)
+		raw := strings.TrimRight(scnr.Text(), " \t")
+		line := strings.TrimSpace(raw)
 		line_ := strings.ToLower(line)
 
 		// The most basic criteria for finding a section.
@@ -81,7 +84,14 @@ func parseSections(commentBlock string) []Section {
 			}
 			section = new(Section)
 			section.Title = strings.TrimSuffix(line, ":")
+			section.Index = titles[line_]
+			titles[line_]++
+			indented = line_ == "openapi raw:"
 			body = bytes.NewBuffer(nil)
+		} else if indented {
+			// The indentation of YAML is significant, so it is kept until it
+			// can be cleaned up uniformly.
+			fmt.Fprintln(body, raw)
 		} else {
 			fmt.Fprintln(body, line)
 		}
@@ -137,7 +147,7 @@ func trimPrefixMultiline(s string, prefix string) string {
 		}
 
 		// If everything went well, write the trimmed line to the output buffer.
-		fmt.Fprintln(out, strings.TrimPrefix(s, prefix))
+		fmt.Fprintln(out, strings.TrimPrefix(line, prefix))
 	}
 
 	return out.String()
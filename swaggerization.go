@@ -3,6 +3,8 @@ package main
 import (
 	"github.com/go-openapi/spec"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -11,21 +13,44 @@ func swaggerizeApi(intermediate ApiIntermediate) *spec.Swagger {
 	var info *spec.Info = &spec.Info{
 		// This is ugly, but apparently you can't do direct assignment on embedded members.
 		InfoProps: spec.InfoProps{
-			Description: intermediate.ApiDescription,
-			Title:       intermediate.ApiTitle,
-			Version:     intermediate.ApiVersion,
+			Description:    intermediate.ApiDescription,
+			Title:          intermediate.ApiTitle,
+			Version:        intermediate.ApiVersion,
+			TermsOfService: intermediate.TermsOfService,
 		},
 	}
 
+	if intermediate.ContactName != "" || intermediate.ContactEmail != "" || intermediate.ContactUrl != "" {
+		info.Contact = &spec.ContactInfo{
+			Name:  intermediate.ContactName,
+			Email: intermediate.ContactEmail,
+			URL:   intermediate.ContactUrl,
+		}
+	}
+
+	if intermediate.LicenseName != "" || intermediate.LicenseUrl != "" {
+		info.License = &spec.License{
+			Name: intermediate.LicenseName,
+			URL:  intermediate.LicenseUrl,
+		}
+	}
+
 	var swagger *spec.Swagger = &spec.Swagger{
 		// This is ugly, but apparently you can't do direct assignment on embedded members.
 		SwaggerProps: spec.SwaggerProps{
-			BasePath: intermediate.BasePath,
-			Info:     info,
-			Swagger:  "2.0",
+			BasePath:     intermediate.BasePath,
+			Consumes:     intermediate.Consumes,
+			ExternalDocs: intermediate.ExternalDocs.ExternalDocs(),
+			Host:         intermediate.Host,
+			Info:         info,
+			Produces:     intermediate.Produces,
+			Schemes:      intermediate.Schemes,
+			Swagger:      "2.0",
 		},
 	}
 
+	addExtensions(&swagger.VendorExtensible, intermediate.Extensions)
+
 	//for _, subApi := range intermediate.SubApis{
 	//	swagger.Paths.Paths[subApi.Path] = spec.PathItem{}
 	//}
@@ -39,7 +64,7 @@ See the following for reference:
 	https://github.com/OAI/OpenAPI-Specification/blob/master/versions/2.0.md
 	https://godoc.org/github.com/go-openapi/spec
 */
-func swaggerizeOperations(intermediates []OperationIntermediate) *spec.Paths {
+func swaggerizeOperations(intermediates []OperationIntermediate, store DefinitionStore) *spec.Paths {
 
 	pathItems := make(map[string]spec.PathItem)
 
@@ -52,14 +77,18 @@ func swaggerizeOperations(intermediates []OperationIntermediate) *spec.Paths {
 
 		operationObject := &spec.Operation{
 			OperationProps: spec.OperationProps{
-				Summary:     operationIntermediate.Summary,
-				Description: operationIntermediate.Description,
-				Consumes:    operationIntermediate.Accepts,
-				Produces:    operationIntermediate.Accepts,
-				Tags:        operationIntermediate.Tags,
+				Summary:      operationIntermediate.Summary,
+				Description:  operationIntermediate.Description,
+				Consumes:     operationIntermediate.Consumes,
+				Produces:     operationIntermediate.Produces,
+				Tags:         operationIntermediate.Tags,
+				ExternalDocs: operationIntermediate.ExternalDocs.ExternalDocs(),
 			},
 		}
 
+		operationObject.ID = operationIntermediate.OperationId
+		addExtensions(&operationObject.VendorExtensible, operationIntermediate.Extensions)
+
 		for _, responseIntermediate := range operationIntermediate.Responses {
 			response := new(spec.Response)
 			response.Description = responseIntermediate.Description
@@ -68,25 +97,38 @@ func swaggerizeOperations(intermediates []OperationIntermediate) *spec.Paths {
 		}
 
 		for _, parameterIntermediate := range operationIntermediate.Parameters {
+
+			if parameterIntermediate.In == "body" {
+				parameters := swaggerizeRequestBody(parameterIntermediate, operationIntermediate.PackagePath, store)
+				for _, parameter := range parameters {
+					operationObject.AddParam(parameter)
+				}
+				continue
+			}
+
 			parameter := new(spec.Parameter)
-			parameter.Name = parameterIntermediate.Type.JsonName
+			parameter.Name = parameterIntermediate.Name
 			parameter.In = parameterIntermediate.In
 			parameter.Required = parameterIntermediate.Required
 			parameter.Description = parameterIntermediate.Description
 
-			if parameterIntermediate.In == "body" {
-				parameter.Schema = parameterIntermediate.Schema()
-			} else {
-				isPrimitive, t, _ := IsPrimitive(parameterIntermediate.Type.Type)
-				parameter.Type = t
-				if !isPrimitive {
-					log.Print("WARNING: It appears there is non-primitive response parameter someplace other than the request body:" + parameterIntermediate.Type.CanonicalName())
-				}
+			isPrimitive, t, _ := IsPrimitive(parameterIntermediate.Type.GoType())
+			parameter.Type = t
+			if !isPrimitive {
+				log.Print("WARNING: It appears there is non-primitive response parameter someplace other than the request body:" + parameterIntermediate.Type.GoType())
 			}
 
 			operationObject.AddParam(parameter)
 		}
 
+		swaggerizeExamples(operationObject, operationIntermediate)
+
+		// The raw fragments take precedence over everything else.
+		err := mergeRaw(operationObject, operationIntermediate.Raw)
+		if err != nil {
+			log.Print("ERROR: ", err)
+		}
+
 		switch strings.ToLower(operationIntermediate.Method) {
 		case "put":
 			pathItem.Put = operationObject
@@ -112,6 +154,212 @@ func swaggerizeOperations(intermediates []OperationIntermediate) *spec.Paths {
 	return paths
 }
 
+// Response examples are keyed by media type. If the example doesn't specify
+// one, the first JSON media type produced by the operation is used. Request
+// examples become the 'x-example' vendor extension of the body parameter.
+func swaggerizeExamples(operationObject *spec.Operation, intermediate OperationIntermediate) {
+
+	defaultMediaType := "application/json"
+	for _, mediaType := range intermediate.Produces {
+		if strings.Contains(mediaType, "json") {
+			defaultMediaType = mediaType
+			break
+		}
+	}
+
+	for _, example := range intermediate.Examples {
+
+		if example.Value == nil {
+			// This is an unresolved reference.
+			continue
+		}
+
+		if example.Key == "request" {
+			found := false
+			for i, parameter := range operationObject.Parameters {
+				if parameter.In == "body" {
+					operationObject.Parameters[i].AddExtension("x-example", example.Value)
+					found = true
+				}
+			}
+
+			if !found {
+				log.Printf("WARNING: Request example given for %s %s, but it has no body parameter.", intermediate.Method, intermediate.Path)
+			}
+
+			continue
+		}
+
+		mediaType := example.MediaType
+		if mediaType == "" {
+			mediaType = defaultMediaType
+		}
+
+		if operationObject.Responses == nil {
+			log.Printf("WARNING: Example given for response '%s' of %s %s, but there are no responses.", example.Key, intermediate.Method, intermediate.Path)
+			continue
+		}
+
+		if example.Key == "default" {
+			if operationObject.Responses.Default == nil {
+				log.Printf("WARNING: Example given for the default response of %s %s, but there is no default response.", intermediate.Method, intermediate.Path)
+				continue
+			}
+
+			addResponseExample(operationObject.Responses.Default, mediaType, example.Value)
+			continue
+		}
+
+		statusCode, _ := strconv.Atoi(example.Key)
+		response, ok := operationObject.Responses.StatusCodeResponses[statusCode]
+		if !ok {
+			log.Printf("WARNING: Example given for response '%s' of %s %s, but there is no such response.", example.Key, intermediate.Method, intermediate.Path)
+			continue
+		}
+
+		addResponseExample(&response, mediaType, example.Value)
+		operationObject.Responses.StatusCodeResponses[statusCode] = response
+	}
+}
+
+func addResponseExample(response *spec.Response, mediaType string, value interface{}) {
+	if response.Examples == nil {
+		response.Examples = make(map[string]interface{})
+	}
+
+	response.Examples[mediaType] = value
+}
+
+/*
+Swagger 2.0 describes a request body either as a single body parameter or as a
+set of form parameters, but never both. Form parameters are only generated when
+every media type declared for the body is a form media type. Otherwise, a body
+parameter is generated, and any types given per media type are described by the
+'x-media-type-schemas' vendor extension.
+*/
+func swaggerizeRequestBody(intermediate ParameterIntermediate, referringPackage string, store DefinitionStore) []*spec.Parameter {
+
+	var (
+		formMediaType *MediaTypeIntermediate
+		otherFound    bool = len(intermediate.MediaTypes) == 0
+		distinctTypes bool
+	)
+
+	for i, mediaType := range intermediate.MediaTypes {
+		if isFormMediaType(mediaType.MediaType) {
+			if formMediaType == nil {
+				formMediaType = &intermediate.MediaTypes[i]
+			}
+		} else {
+			otherFound = true
+		}
+
+		if mediaType.Type.GoType() != intermediate.Type.GoType() {
+			distinctTypes = true
+		}
+	}
+
+	if !otherFound {
+		return swaggerizeFormParameters(intermediate, *formMediaType, referringPackage, store)
+	}
+
+	if formMediaType != nil {
+		log.Printf("WARNING: Swagger 2.0 can't describe both a body and form parameters. The form media types of body '%s' are only described by x-media-type-schemas.", intermediate.Name)
+		distinctTypes = true
+	}
+
+	parameter := new(spec.Parameter)
+	parameter.Name = intermediate.Name
+	parameter.In = "body"
+	parameter.Schema = intermediate.Schema()
+	parameter.Required = intermediate.Required
+	parameter.Description = intermediate.Description
+
+	if distinctTypes {
+		schemas := make(map[string]*spec.Schema)
+		for _, mediaType := range intermediate.MediaTypes {
+			schemas[mediaType.MediaType] = mediaType.Schema()
+		}
+		parameter.AddExtension("x-media-type-schemas", schemas)
+	}
+
+	return []*spec.Parameter{parameter}
+}
+
+// A raw body becomes a single file parameter. A struct body becomes a form
+// parameter per property. Properties that aren't primitives (or collections of
+// primitives) can't be form parameters and are skipped.
+func swaggerizeFormParameters(intermediate ParameterIntermediate, mediaType MediaTypeIntermediate, referringPackage string, store DefinitionStore) []*spec.Parameter {
+
+	parameters := make([]*spec.Parameter, 0)
+
+	if isPrimitive, t, f := IsPrimitive(mediaType.Type.GoType()); isPrimitive {
+		parameter := spec.FormDataParam(intermediate.Name).Typed(t, f)
+		if t == "string" && f == "binary" {
+			parameter = spec.FileParam(intermediate.Name)
+		}
+
+		parameter.Required = intermediate.Required
+		parameter.Description = intermediate.Description
+
+		return append(parameters, parameter)
+	}
+
+	def, ok := store.ExistsDefinition(referringPackage, mediaType.Type.GoType())
+	if !ok {
+		log.Print("WARNING: Definition not found for form body: " + mediaType.Type.GoType())
+		return parameters
+	}
+
+	members := def.AllMembers()
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		member := members[name]
+		schema := member.Schema()
+
+		var parameter *spec.Parameter
+
+		switch {
+		case schema.Ref.String() != "" || len(schema.AllOf) > 0:
+			log.Printf("WARNING: Form body property '%s' of '%s' is not a primitive type.", schema.Title, def.Name)
+			continue
+		case schema.Type.Contains("array"):
+			items := schema.Items.Schema
+			if items == nil || items.Ref.String() != "" || len(items.AllOf) > 0 || items.Type.Contains("object") {
+				log.Printf("WARNING: Form body property '%s' of '%s' is not a collection of primitive types.", schema.Title, def.Name)
+				continue
+			}
+
+			parameter = spec.FormDataParam(schema.Title)
+			parameter.CollectionOf(spec.NewItems().Typed(items.Type[0], items.Format), "multi")
+		case schema.Type.Contains("object"):
+			log.Printf("WARNING: Form body property '%s' of '%s' is not a primitive type.", schema.Title, def.Name)
+			continue
+		case schema.Type.Contains("string") && schema.Format == "binary":
+			parameter = spec.FileParam(schema.Title)
+		default:
+			parameter = spec.FormDataParam(schema.Title).Typed(schema.Type[0], schema.Format)
+		}
+
+		parameter.Required = def.IsMemberRequired(name)
+		parameter.Description = schema.Description
+
+		parameters = append(parameters, parameter)
+	}
+
+	return parameters
+}
+
+func isFormMediaType(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	return strings.HasPrefix(mediaType, "application/x-www-form-urlencoded") || strings.HasPrefix(mediaType, "multipart/form-data")
+}
+
 func swaggerizeDefinitions(store DefinitionStore) map[string]spec.Schema {
 
 	schemas := make(map[string]spec.Schema)
@@ -131,8 +379,9 @@ func swaggerizeTags(intermediates []TagIntermediate) []spec.Tag {
 	for _, intermediate := range intermediates {
 		tag := spec.Tag{
 			TagProps: spec.TagProps{
-				Name:        intermediate.Name,
-				Description: intermediate.Description,
+				Name:         intermediate.Name,
+				Description:  intermediate.Description,
+				ExternalDocs: intermediate.ExternalDocs.ExternalDocs(),
 			},
 		}
 
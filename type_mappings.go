@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/go-openapi/spec"
+	"github.com/jackmanlabs/errors"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+/*
+Some types are better described by hand than by their declarations, e.g. types
+from third-party packages that marshal themselves. The type mappings file maps
+fully qualified Go types (import path and type name) to schemas, either as a
+'type/format' shorthand or as a complete schema:
+
+	github.com/google/uuid.UUID: string/uuid
+	cloud.google.com/go/civil.Date: string/date
+	github.com/shopspring/decimal.Decimal:
+	  type: string
+	  format: decimal
+	  example: "12.34"
+
+The file may be JSON or YAML.
+*/
+func loadTypeMappings(filePath string) (map[string]*spec.Schema, error) {
+
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	var values map[string]interface{}
+	err = parseJsonOrYaml(string(b), &values)
+	if err != nil {
+		return nil, errors.Newf("%s: %s", filePath, err)
+	}
+
+	mappings := make(map[string]*spec.Schema)
+
+	for goType, value := range values {
+		if !strings.Contains(goType, ".") {
+			return nil, errors.Newf("%s: The type '%s' isn't qualified by its import path.", filePath, goType)
+		}
+
+		schema, err := parseTypeMapping(value)
+		if err != nil {
+			return nil, errors.Newf("%s: The schema of '%s' is invalid: %s", filePath, goType, err)
+		}
+
+		mappings[goType] = schema
+	}
+
+	return mappings, nil
+}
+
+func parseTypeMapping(value interface{}) (*spec.Schema, error) {
+
+	schema := new(spec.Schema)
+
+	if shorthand, ok := value.(string); ok {
+		parts := strings.SplitN(shorthand, "/", 2)
+		parts = append(parts, "")
+
+		return schema.Typed(parts[0], parts[1]), nil
+	}
+
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, errors.New("Expected a 'type/format' string or a schema object.")
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	err = json.Unmarshal(b, schema)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	return schema, nil
+}
+
+// The standard library types that encoding/json doesn't marshal as their
+// declarations suggest, or whose declarations can't be described, are mapped
+// to the schemas of what encoding/json actually produces. These may be
+// overridden by the type mappings file.
+func builtinTypeMappings() map[string]*spec.Schema {
+
+	str := func(format string) *spec.Schema {
+		return new(spec.Schema).Typed("string", format)
+	}
+
+	// url.URL has no marshalers, so it's an object of its fields.
+	url := new(spec.Schema).Typed("object", "")
+	for _, name := range []string{"Scheme", "Opaque", "Host", "Path", "RawPath", "RawQuery", "Fragment", "RawFragment"} {
+		url.SetProperty(name, *str(""))
+	}
+	user := new(spec.Schema).Typed("object", "")
+	user.AddExtension("x-nullable", true)
+	url.SetProperty("User", *user)
+	url.SetProperty("OmitHost", *new(spec.Schema).Typed("boolean", ""))
+	url.SetProperty("ForceQuery", *new(spec.Schema).Typed("boolean", ""))
+
+	return map[string]*spec.Schema{
+		// Nanoseconds.
+		"time.Duration": new(spec.Schema).Typed("integer", "int64"),
+		"time.Month":    new(spec.Schema).Typed("integer", ""),
+		"time.Weekday":  new(spec.Schema).Typed("integer", ""),
+
+		// Any JSON value at all.
+		"encoding/json.RawMessage": new(spec.Schema),
+
+		// A number literal, of arbitrary precision.
+		"encoding/json.Number": new(spec.Schema).Typed("number", ""),
+		"math/big.Int":         new(spec.Schema).Typed("integer", ""),
+
+		// These only implement encoding.TextMarshaler.
+		"math/big.Float":     str(""),
+		"math/big.Rat":       str(""),
+		"net.IP":             str("ip"),
+		"net/netip.Addr":     str("ip"),
+		"net/netip.AddrPort": str(""),
+		"net/netip.Prefix":   str(""),
+
+		"net/url.URL": url,
+
+		// Permission and mode bits.
+		"os.FileMode":    new(spec.Schema).Typed("integer", "uint32"),
+		"io/fs.FileMode": new(spec.Schema).Typed("integer", "uint32"),
+	}
+}
+
+// This returns the schema mapped to the Go type, as referenced in the given
+// package.
+func mappedSchema(referringPackage, goType string) (*spec.Schema, bool) {
+
+	if len(typeMappings) == 0 {
+		return nil, false
+	}
+
+	goType = strings.TrimLeft(goType, "*")
+
+	for _, qualifiedType := range qualifyType(referringPackage, goType) {
+		if schema, ok := typeMappings[qualifiedType]; ok {
+			return schema, true
+		}
+	}
+
+	return nil, false
+}
+
+// By convention, the name of a package is the last element of its path, not
+// counting a major version, e.g. 'bar' for 'github.com/foo/bar/v2' and 'yaml'
+// for 'gopkg.in/yaml.v3'.
+func guessPackageName(importPath string) string {
+
+	rxMajorVersion := regexp.MustCompile(`^v[0-9]+$`)
+	rxVersionSuffix := regexp.MustCompile(`\.v[0-9]+$`)
+
+	name := path.Base(importPath)
+	if rxMajorVersion.MatchString(name) && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+
+	return rxVersionSuffix.ReplaceAllString(name, "")
+}
+
+// This returns the possible fully qualified names (import path and type name)
+// of the Go type, as referenced in the given package.
+func qualifyType(referringPackage, goType string) []string {
+
+	pkgInfo := pkgInfos[referringPackage]
+	importPaths := possibleImportPaths(pkgInfo, goType)
+
+	alias, name := splitTypeName(goType)
+
+	// Packages that aren't available aren't scanned, so their names are
+	// unknown, and have to be guessed.
+	if len(importPaths) == 0 && alias != "" {
+		for importPath := range pkgInfo.Imports {
+			if guessPackageName(importPath) == alias {
+				importPaths = append(importPaths, importPath)
+			}
+		}
+	}
+
+	qualifiedTypes := make([]string, 0, len(importPaths))
+	for _, importPath := range importPaths {
+		qualifiedTypes = append(qualifiedTypes, importPath+"."+name)
+	}
+
+	return qualifiedTypes
+}
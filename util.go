@@ -1,7 +1,8 @@
 package main
 
 import (
-	"regexp"
+	"os"
+	"strconv"
 	"strings"
 )
 
@@ -24,6 +25,12 @@ func shouldIgnore(path string) bool {
 	return false
 }
 
+// This filters the test files out of parsed package directories. They may
+// declare anything, even in another package.
+func notTest(fi os.FileInfo) bool {
+	return !strings.HasSuffix(fi.Name(), "_test.go")
+}
+
 // Returns true if primitive.
 // If the type is primitive, the string parameters indicate the type and format per swagger spec.
 // http://swagger.io/specification/ (Data Types)
@@ -34,7 +41,7 @@ func IsPrimitive(goType string) (bool, string, string) {
 	goType = strings.Trim(goType, "*")
 
 	switch goType {
-	case "[]byte":
+	case "[]byte", "[]uint8":
 		return true, "string", "binary"
 	case "bool":
 		return true, "boolean", ""
@@ -58,7 +65,7 @@ func IsPrimitive(goType string) (bool, string, string) {
 		return true, "integer", "int32"
 	case "int64":
 		return true, "integer", "int64"
-	case "interface{}":
+	case "interface{}", "any":
 		return true, "object", ""
 	case "rune":
 		return true, "integer", ""
@@ -77,6 +84,10 @@ func IsPrimitive(goType string) (bool, string, string) {
 	case "uintptr":
 		return true, "integer", ""
 
+	case "io.Reader", "io.ReadCloser":
+		// Raw request and response bodies are binary strings.
+		return true, "string", "binary"
+
 	case "time.Time":
 		// This is a special case. While not strictly a primitive type, it's
 		// something we can all agree is as simple as it needs to be.
@@ -93,28 +104,100 @@ func IsPrimitive(goType string) (bool, string, string) {
 	return false, "", ""
 }
 
+// Pointers may be null. Other types are never null, except for nil slices and
+// maps, which are best left out with omitempty.
+func IsNullable(goType string) bool {
+	return strings.HasPrefix(goType, "*")
+}
+
+// A member is required when it's validated as such. When required-ness follows
+// encoding/json, it's also required whenever encoding/json always emits it,
+// i.e. without omitempty.
+func isRequired(validations Validator, omitEmpty bool) bool {
+
+	if validations != nil && validations.IsRequired() {
+		return true
+	}
+
+	return *required == "json" && !omitEmpty
+}
+
+// This returns the key and value types of a map type. Only the outermost map is
+// taken apart, e.g. 'map[string][]*Village' has the value type '[]*Village'.
 func IsMap(goType string) (bool, string, string) {
 
-	rxMap := regexp.MustCompile(`map\[(.+)\]\**(.+)`)
-	if rxMap.MatchString(goType) {
-		matches := rxMap.FindStringSubmatch(goType)
-		return true, matches[1], matches[2]
+	goType = strings.TrimLeft(goType, "*")
+
+	if !strings.HasPrefix(goType, "map[") {
+		return false, "", ""
 	}
 
-	return false, "", ""
+	end := matchingBracket(goType, len("map"))
+	if end < 0 {
+		return false, "", ""
+	}
+
+	return true, goType[len("map["):end], goType[end+1:]
 }
 
 func IsSlice(goType string) (bool, string) {
 
+	goType = strings.TrimLeft(goType, "*")
+
 	// This is a strange case. The Swagger spec doesn't recognize []byte as an
 	// array, but as a binary string.
-	if goType == "[]byte" {
+	if goType == "[]byte" || goType == "[]uint8" {
 		return false, ""
 	}
 
-	if strings.HasPrefix(goType, "[]") {
-		return true, goType[2:]
+	// Fixed-size arrays, e.g. '[4]float64', are arrays in JSON too.
+	if strings.HasPrefix(goType, "[") {
+		if end := matchingBracket(goType, 0); end > -1 {
+			return true, goType[end+1:]
+		}
 	}
 
 	return false, ""
 }
+
+// This returns the length of a fixed-size array type, e.g. 4 for '[4]float64',
+// or -1 if the type isn't one, or its length isn't a literal.
+func ArrayLength(goType string) int64 {
+
+	goType = strings.TrimLeft(goType, "*")
+
+	if !strings.HasPrefix(goType, "[") {
+		return -1
+	}
+
+	end := matchingBracket(goType, 0)
+	if end < 0 {
+		return -1
+	}
+
+	length, err := strconv.ParseInt(goType[1:end], 0, 64)
+	if err != nil {
+		return -1
+	}
+
+	return length
+}
+
+// This returns the index of the bracket closing the one at the given index.
+func matchingBracket(s string, open int) int {
+
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
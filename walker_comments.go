@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"github.com/jackmanlabs/errors"
 	"go/ast"
 	"go/build"
@@ -10,11 +11,11 @@ import (
 	"strings"
 )
 
-func getCommentBlocks(pkgPath string) ([]string, error) {
+func getCommentBlocks(pkgPath string) ([]CommentBlock, error) {
 
 	bpkg, err := build.Import(pkgPath, srcPath, 0)
 	if err != nil {
-		return []string{}, nil
+		return []CommentBlock{}, nil
 	}
 
 	fset := token.NewFileSet()
@@ -25,7 +26,7 @@ func getCommentBlocks(pkgPath string) ([]string, error) {
 
 	commentVisitor := &CommentVisitor{
 		Fset:     fset,
-		Comments: make([]string, 0),
+		Comments: make([]CommentBlock, 0),
 	}
 	for _, pkg := range pkgs {
 		//log.Print("Package name: ", pkg.Name)
@@ -40,7 +41,7 @@ We're using a map for the imports so we don't have to worry about duplicates.
 */
 type CommentVisitor struct {
 	Fset     *token.FileSet
-	Comments []string
+	Comments []CommentBlock
 }
 
 func (this *CommentVisitor) Visit(node ast.Node) (w ast.Visitor) {
@@ -67,8 +68,12 @@ func (this *CommentVisitor) Visit(node ast.Node) (w ast.Visitor) {
 		for _, commentGroup := range t.Comments {
 			s := commentGroup.Text()
 			// We don't need all the comments, so let's save some memory/CPU.
-			if strings.Contains(s, "OpenAPI") {
-				this.Comments = append(this.Comments, s)
+			if strings.Contains(s, "OpenAPI") || strings.Contains(s, "@Router") {
+				this.Comments = append(this.Comments, CommentBlock{
+					Text:  s,
+					Fset:  this.Fset,
+					Group: commentGroup,
+				})
 			}
 		}
 
@@ -82,33 +87,88 @@ func (this *CommentVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	return this
 }
 
+// A comment block remembers where it came from, so that problems can be
+// reported where they are found. Identical blocks may come from different
+// places.
+type CommentBlock struct {
+	Text  string
+	Fset  *token.FileSet
+	Group *ast.CommentGroup
+}
+
+// This returns the position (file:line) of the title of the section in the
+// source code. If the comment block's source is unknown, an empty string is
+// returned.
+func sectionPosition(commentBlock CommentBlock, section Section) string {
+
+	if commentBlock.Group == nil {
+		return ""
+	}
+
+	title := strings.ToLower(strings.TrimSpace(section.Title)) + ":"
+	count := 0
+
+	for _, comment := range commentBlock.Group.List {
+		position := commentBlock.Fset.Position(comment.Pos())
+
+		for i, line := range strings.Split(comment.Text, "\n") {
+			line = strings.TrimSpace(line)
+			line = strings.TrimPrefix(line, "//")
+			line = strings.TrimPrefix(line, "/*")
+			line = strings.TrimSuffix(line, "*/")
+			line = strings.ToLower(strings.TrimSpace(line))
+
+			if line != title {
+				continue
+			}
+
+			if count == section.Index {
+				return fmt.Sprintf("%s:%d", position.Filename, position.Line+i)
+			}
+			count++
+		}
+	}
+
+	return commentBlock.Fset.Position(commentBlock.Group.Pos()).String()
+}
+
 // This is used to detect blocks with 'OpenAPI Path:'. A comment block that describes a path/operation is useless if it
 // fails to describe the path. Therefore, this is a good indicator.
-func detectOperationComments(commentBlocks []string) []string {
-	return detectComments(commentBlocks, "OpenAPI Path:")
+func detectOperationComments(commentBlocks []CommentBlock) []CommentBlock {
+
+	detectedBlocks := detectComments(commentBlocks, "OpenAPI Path:")
+
+	// The @-annotation dialect is detected by its '@Router' annotation.
+	for _, commentBlock := range detectComments(commentBlocks, "@Router") {
+		if isAnnotatedOperation(commentBlock.Text) {
+			detectedBlocks = append(detectedBlocks, commentBlock)
+		}
+	}
+
+	return detectedBlocks
 }
 
 // This detects comments blocks with 'OpenAPI API Title:'. The API Title is a required member of the Swagger definition,
 // so it must be present.
-func detectApiCommentBlocks(commentBlocks []string) []string {
+func detectApiCommentBlocks(commentBlocks []CommentBlock) []CommentBlock {
 	return detectComments(commentBlocks, "OpenAPI API Title:")
 }
 
 // This detects comment blocks with 'OpenAPI Tag:'. There is no garantee that these tags declarations will be a part of
-// any other comment block.
-func detectTagComments(commentBlocks []string) []string {
-	return detectComments(commentBlocks, "OpenAPI Tags:")
+// any other comment block. Note that this does not match the 'OpenAPI Tags:' section of operations.
+func detectTagComments(commentBlocks []CommentBlock) []CommentBlock {
+	return detectComments(commentBlocks, "OpenAPI Tag:")
 }
 
 // Comment detection is case-insensitive.
 // Any comment blocks that prove to have the test string will be returned.
-func detectComments(commentBlocks []string, keyword string) []string {
+func detectComments(commentBlocks []CommentBlock, keyword string) []CommentBlock {
 
 	keyword = strings.ToLower(keyword)
-	detectedBlocks := make([]string, 0)
+	detectedBlocks := make([]CommentBlock, 0)
 
 	for _, comment := range commentBlocks {
-		comment_ := strings.ToLower(comment)
+		comment_ := strings.ToLower(comment.Text)
 		if strings.Contains(comment_, keyword) {
 			detectedBlocks = append(detectedBlocks, comment)
 		}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"fmt"
+	"github.com/go-openapi/spec"
 	"github.com/jackmanlabs/bucket/jlog"
 	"github.com/jackmanlabs/errors"
 	"go/ast"
 	"go/build"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"log"
 	"regexp"
 	"strings"
@@ -17,7 +19,8 @@ import (
 func findDefinition(referringPackage, typeName string) (*DefinitionIntermediate, error) {
 
 	pkgInfo := pkgInfos[referringPackage]
-	typeName = strings.TrimPrefix(typeName, "*")
+	typeName = strings.TrimLeft(typeName, "*")
+	typeName = absoluteTypeArgs(referringPackage, typeName)
 	importPaths := possibleImportPaths(pkgInfo, typeName)
 
 	if len(importPaths) == 0 {
@@ -30,10 +33,8 @@ func findDefinition(referringPackage, typeName string) (*DefinitionIntermediate,
 		jlog.Log(importPaths)
 	}
 
-	if strings.Contains(typeName, ".") {
-		index := strings.Index(typeName, ".") + 1
-		typeName = typeName[index:]
-	}
+	_, typeName = splitTypeName(typeName)
+	genericName, typeArgs := parseTypeArgs(typeName)
 
 	for _, importPath := range importPaths {
 
@@ -47,7 +48,7 @@ func findDefinition(referringPackage, typeName string) (*DefinitionIntermediate,
 		}
 
 		fset := token.NewFileSet()
-		pkgs, err := parser.ParseDir(fset, bpkg.Dir, nil, parser.AllErrors|parser.ParseComments)
+		pkgs, err := parser.ParseDir(fset, bpkg.Dir, notTest, parser.AllErrors|parser.ParseComments)
 		if err != nil {
 			return nil, errors.Stack(err)
 		}
@@ -55,7 +56,8 @@ func findDefinition(referringPackage, typeName string) (*DefinitionIntermediate,
 		for _, pkg := range pkgs {
 			definitionVisitor := &DefinitionVisitor{
 				Fset:     fset,
-				TypeName: typeName,
+				TypeName: genericName,
+				TypeArgs: typeArgs,
 			}
 
 			ast.Walk(definitionVisitor, pkg)
@@ -63,9 +65,21 @@ func findDefinition(referringPackage, typeName string) (*DefinitionIntermediate,
 			if definitionVisitor.Definition != nil {
 
 				definition := definitionVisitor.Definition
+				definition.Name = typeName
 				definition.PackageName = pkg.Name
 				definition.PackagePath = importPath
 
+				for name, pointerReceiver := range definitionVisitor.methods {
+					definition.Methods[name] = pointerReceiver
+				}
+
+				applyMarshalers(definition)
+
+				for _, inline := range definition.InlineDefinitions() {
+					inline.PackageName = pkg.Name
+					inline.PackagePath = importPath
+				}
+
 				// If this definition is an enum (underlying type is primitive), then we assume it's an enum type that needs enum values.
 				if isPrimitive, _, _ := IsPrimitive(definition.UnderlyingType); isPrimitive {
 					values, err := findEnumValues(definition.PackagePath, definition.Name)
@@ -73,10 +87,7 @@ func findDefinition(referringPackage, typeName string) (*DefinitionIntermediate,
 						return nil, errors.Stack(err)
 					}
 
-					definition.Enums = make([]interface{}, 0)
-					for _, v := range values {
-						definition.Enums = append(definition.Enums, v)
-					}
+					definition.Enums = values
 				}
 
 				return definition, nil
@@ -90,7 +101,19 @@ func findDefinition(referringPackage, typeName string) (*DefinitionIntermediate,
 type DefinitionVisitor struct {
 	Fset       *token.FileSet
 	TypeName   string
+	TypeArgs   []string // The type arguments of an instantiation of a generic type.
 	Definition *DefinitionIntermediate
+
+	// The type parameters are replaced by the type arguments in the types of
+	// the fields.
+	substitutions map[string]string // map[typeParam]typeArg
+
+	// For an ungrouped type declaration, the documentation is attached to the
+	// declaration instead of the type spec.
+	declDoc *ast.CommentGroup
+
+	// The methods of the type may be declared before the type itself.
+	methods map[string]bool // map[name]pointerReceiver
 }
 
 func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
@@ -110,19 +133,71 @@ func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
 
 	switch t := node.(type) {
 
+	case *ast.GenDecl:
+		this.declDoc = nil
+		if t.Tok == token.TYPE && len(t.Specs) == 1 {
+			this.declDoc = t.Doc
+		}
 	case *ast.TypeSpec:
 		if t.Name.String() == this.TypeName {
+			doc := t.Doc
+			if doc == nil {
+				doc = this.declDoc
+			}
+
+			controls := parseOpenApiControls(doc.Text())
+			schemaType, schemaFormat := parseSchemaAnnotation(doc.Text())
+
+			this.substitutions = make(map[string]string)
+			if t.TypeParams != nil {
+				typeParams := make([]string, 0)
+				for _, field := range t.TypeParams.List {
+					for _, ident := range field.Names {
+						typeParams = append(typeParams, ident.Name)
+					}
+				}
+
+				if len(typeParams) != len(this.TypeArgs) {
+					log.Printf("WARNING: Generic type '%s' has %d type parameters, but %d type arguments were given.", this.TypeName, len(typeParams), len(this.TypeArgs))
+				}
+
+				for i := 0; i < len(typeParams) && i < len(this.TypeArgs); i++ {
+					this.substitutions[typeParams[i]] = this.TypeArgs[i]
+				}
+			} else if len(this.TypeArgs) > 0 {
+				log.Printf("WARNING: Type '%s' isn't generic, but type arguments were given.", this.TypeName)
+			}
+
 			this.Definition = &DefinitionIntermediate{
-				Name:           t.Name.String(),
-				Comment:        t.Comment.Text(),
-				Documentation:  t.Doc.Text(),
-				UnderlyingType: resolveTypeExpression(t.Type),
-				Members:        make(map[string]SchemerDefiner),
-				EmbeddedTypes:  make([]string, 0),
+				Name:            t.Name.String(),
+				Comment:         t.Comment.Text(),
+				Documentation:   doc.Text(),
+				UnderlyingType:  substituteTypeParams(resolveTypeExpression(t.Type), this.substitutions),
+				Members:         make(map[string]SchemerDefiner),
+				EmbeddedTypes:   make([]string, 0),
+				Depths:          make(map[string]int),
+				Optional:        make(map[string]bool),
+				Methods:         make(map[string]bool),
+				MethodDepths:    make(map[string]int),
+				ExternalDocs:    parseExternalDocsAnnotation(doc.Text()),
+				ExampleRef:      parseExampleAnnotation(doc.Text()),
+				SchemaType:      schemaType,
+				SchemaFormat:    schemaFormat,
+				Discriminator:   parseDiscriminatorAnnotation(doc.Text()),
+				Implementations: parseImplementationAnnotations(doc.Text()),
+				Extensions:      controls.Extensions,
+				Deprecated:      controls.Deprecated,
 			}
+
+			// The type parameters aren't fields of the definition.
+			ast.Walk(this, t.Type)
+			return nil
 		} else {
 			return nil
 		}
+	case *ast.InterfaceType:
+		// The methods of an interface aren't fields.
+		return nil
 	case *ast.Field:
 
 		controlsDoc := parseOpenApiControls(t.Doc.Text())
@@ -131,6 +206,11 @@ func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
 		controls := OpenApiControls{
 			Ignore:     controlsDoc.Ignore || controlsComment.Ignore,
 			Deprecated: controlsDoc.Deprecated || controlsComment.Deprecated,
+			Extensions: controlsDoc.Extensions,
+		}
+
+		for name, value := range controlsComment.Extensions {
+			controls.Extensions.Add(name, value)
 		}
 
 		if controls.Ignore {
@@ -142,34 +222,28 @@ func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
 			return nil
 		}
 
-		if len(t.Names) > 1 {
-			// On our test code base, this was never printed.
-			log.Print("WARNING: Multiple names discovered.")
-		}
-
-		// Handle embedded structs.
-		if len(t.Names) == 0 {
-			//ast.Fprint(os.Stderr, this.Fset, t, nil)
-
-			embedded := resolveTypeExpression(t.Type)
-			this.Definition.EmbeddedTypes = append(this.Definition.EmbeddedTypes, embedded)
-			return nil
-		}
-
-		name := t.Names[0].String()
-
-		// Ignore fields that are not exported.
-		name_ := []rune(name)
-		if unicode.IsLower(name_[0]) {
-			return nil
-		}
-
 		var (
+			names         []string = make([]string, 0, len(t.Names))
+			embedded      string   // The type of an embedded field.
 			jsonName      string
 			jsonOmitEmpty bool
 			validations   ValidationMap
 		)
 
+		if len(t.Names) == 0 {
+			// An embedded field is named after its type, without any type
+			// arguments.
+			embedded = substituteTypeParams(resolveTypeExpression(t.Type), this.substitutions)
+			_, name := splitTypeName(strings.TrimLeft(embedded, "*"))
+			name, _ = parseTypeArgs(name)
+			names = append(names, name)
+		}
+
+		// A declaration like 'X, Y int' declares several fields.
+		for _, ident := range t.Names {
+			names = append(names, ident.String())
+		}
+
 		if t.Tag != nil {
 			jsonName, jsonOmitEmpty = parseJsonInfo(t.Tag.Value)
 			if jsonName == "-" {
@@ -181,79 +255,126 @@ func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
 			validations = make(ValidationMap)
 		}
 
+		// Like encoding/json, the fields of untagged embedded structs are
+		// promoted to the parent. Whether the embedded type is a struct is only
+		// known once it's found; see embedDefinitions. A tagged embedded
+		// field is just a field.
+		if embedded != "" && jsonName == "" {
+			this.Definition.EmbeddedTypes = append(this.Definition.EmbeddedTypes, embedded)
+			return nil
+		}
+
 		var desc string = parseMemberDescription(t.Doc.Text())
 		if desc == "" {
 			desc = parseMemberDescription(t.Comment.Text())
 		}
 
-		goType := resolveTypeExpression(t.Type)
-
-		var member SchemerDefiner
+		goType := substituteTypeParams(resolveTypeExpression(t.Type), this.substitutions)
 
-		if isMap, k, v := IsMap(goType); isMap {
-			keyType := &MemberIntermediate{
-				Type:        k,
-				Name:        name,
-				Validations: validations,
+		// An anonymous struct is described inline, by a definition of its own
+		// that isn't stored.
+		var inline *DefinitionIntermediate
+		if structType := inlineStructType(t.Type); structType != nil {
+			inline = &DefinitionIntermediate{
+				Name:           this.Definition.Name + "." + names[0],
+				UnderlyingType: "struct",
+				Members:        make(map[string]SchemerDefiner),
+				EmbeddedTypes:  make([]string, 0),
+				Depths:         make(map[string]int),
+				Optional:       make(map[string]bool),
+				Methods:        make(map[string]bool),
+				MethodDepths:   make(map[string]int),
+				Extensions:     make(spec.Extensions),
 			}
 
-			valueType := &MemberIntermediate{
-				Type:        v,
-				Name:        name,
-				Validations: validations,
-			}
+			ast.Walk(&DefinitionVisitor{Fset: this.Fset, Definition: inline, substitutions: this.substitutions}, structType.Fields)
+		}
 
-			member = &MapIntermediate{
-				Name:          name,
-				Type:          goType,
-				JsonName:      jsonName,
-				JsonOmitEmpty: jsonOmitEmpty,
-				ValueType:     valueType,
-				KeyType:       keyType,
-				Description:   desc,
-				Validations:   validations,
-				Deprecated:    controls.Deprecated,
-			}
+		for _, name := range names {
 
-		} else if isSlice, v := IsSlice(goType); isSlice {
-			valueType := &MemberIntermediate{
-				Type:        v,
-				Name:        name,
-				Validations: validations,
+			// Ignore fields that are not exported.
+			name_ := []rune(name)
+			if unicode.IsLower(name_[0]) && embedded == "" {
+				continue
 			}
 
-			member = &SliceIntermediate{
-				Name:          name,
-				Type:          goType,
-				JsonName:      jsonName,
-				JsonOmitEmpty: jsonOmitEmpty,
-				ValueType:     valueType,
-				Description:   desc,
-				Validations:   validations,
-				Deprecated:    controls.Deprecated,
-			}
-		} else {
-			member = &MemberIntermediate{
-				Type:          goType,
-				Name:          name,
-				JsonName:      jsonName,
-				JsonOmitEmpty: jsonOmitEmpty,
-				Description:   desc,
-				Validations:   validations,
-				Deprecated:    controls.Deprecated,
+			var member SchemerDefiner
+
+			if isMap, k, v := IsMap(goType); isMap {
+				keyType := &MemberIntermediate{
+					Type:        k,
+					Name:        name,
+					Validations: validations,
+				}
+
+				valueType := intermediatateElementType(v, name, validations, inline)
+
+				member = &MapIntermediate{
+					Name:          name,
+					Type:          goType,
+					JsonName:      jsonName,
+					JsonOmitEmpty: jsonOmitEmpty,
+					ValueType:     valueType,
+					KeyType:       keyType,
+					Description:   desc,
+					Validations:   validations,
+					Deprecated:    controls.Deprecated,
+					Extensions:    controls.Extensions,
+				}
+
+			} else if isSlice, v := IsSlice(goType); isSlice {
+				valueType := intermediatateElementType(v, name, validations, inline)
+
+				member = &SliceIntermediate{
+					Name:          name,
+					Type:          goType,
+					JsonName:      jsonName,
+					JsonOmitEmpty: jsonOmitEmpty,
+					ValueType:     valueType,
+					Description:   desc,
+					Validations:   validations,
+					Deprecated:    controls.Deprecated,
+					Extensions:    controls.Extensions,
+				}
+			} else {
+				member = &MemberIntermediate{
+					Type:          goType,
+					Name:          name,
+					JsonName:      jsonName,
+					JsonOmitEmpty: jsonOmitEmpty,
+					Description:   desc,
+					Validations:   validations,
+					Deprecated:    controls.Deprecated,
+					Extensions:    controls.Extensions,
+					Inline:        inline,
+				}
 			}
-		}
 
-		this.Definition.Members[name] = member
+			this.Definition.Members[name] = member
+		}
 
 		return nil
 
 	case *ast.FuncDecl:
-		// Ignore function declarations.
+		// Only the methods of the type matter, as they may change how it's
+		// marshalled.
+		if t.Recv != nil && len(t.Recv.List) == 1 {
+			receiver := resolveTypeExpression(t.Recv.List[0].Type)
+			if receiverType, _ := parseTypeArgs(strings.TrimPrefix(receiver, "*")); receiverType == this.TypeName {
+				if this.methods == nil {
+					this.methods = make(map[string]bool)
+				}
+				this.methods[t.Name.Name] = strings.HasPrefix(receiver, "*")
+			}
+		}
 		return nil
 	case *ast.ImportSpec:
 		// Ignore import declarations.
 		return nil
+	case *ast.ValueSpec:
+		// The types of variables aren't our definition, even when they're
+		// anonymous structs.
+		return nil
 	case *ast.FuncType:
 		return nil
 	case nil:
@@ -270,11 +391,24 @@ func resolveTypeExpression(expr ast.Expr) string {
 	case *ast.StarExpr:
 		return "*" + resolveTypeExpression(t.X)
 	case *ast.ArrayType:
+		if t.Len != nil {
+			// The length of a fixed-size array, e.g. '[4]float64', is kept.
+			return "[" + types.ExprString(t.Len) + "]" + resolveTypeExpression(t.Elt)
+		}
 		return "[]" + resolveTypeExpression(t.Elt)
 	case *ast.Ident:
 		return t.Name
 	case *ast.SelectorExpr:
 		return resolveTypeExpression(t.X) + "." + t.Sel.Name
+	case *ast.IndexExpr:
+		// An instantiation of a generic type with a single type argument.
+		return resolveTypeExpression(t.X) + "[" + resolveTypeExpression(t.Index) + "]"
+	case *ast.IndexListExpr:
+		args := make([]string, 0, len(t.Indices))
+		for _, index := range t.Indices {
+			args = append(args, resolveTypeExpression(index))
+		}
+		return resolveTypeExpression(t.X) + "[" + strings.Join(args, ",") + "]"
 	case *ast.MapType:
 		return fmt.Sprintf("map[%s]%s", resolveTypeExpression(t.Key), resolveTypeExpression(t.Value))
 	case *ast.InterfaceType:
@@ -287,6 +421,24 @@ func resolveTypeExpression(expr ast.Expr) string {
 
 }
 
+// This returns the anonymous struct of a field's type, if any, i.e. the struct
+// of 'struct{...}', '*struct{...}', '[]struct{...}' or 'map[string]struct{...}'.
+func inlineStructType(expr ast.Expr) *ast.StructType {
+
+	switch t := expr.(type) {
+	case *ast.StructType:
+		return t
+	case *ast.StarExpr:
+		return inlineStructType(t.X)
+	case *ast.ArrayType:
+		return inlineStructType(t.Elt)
+	case *ast.MapType:
+		return inlineStructType(t.Value)
+	default:
+		return nil
+	}
+}
+
 func parseJsonInfo(s string) (string, bool) {
 	rxJson := regexp.MustCompile(`json:"([^"]+)"`)
 
@@ -358,17 +510,178 @@ func parseValidateTag(s string) map[string]string {
 
 }
 
+// Types may link to external documentation with the following annotation:
+//
+//	@externalDocs https://agame.com/docs/villages "Everything about villages."
+//
+// The description is optional.
+func parseExternalDocsAnnotation(s string) *ExternalDocsIntermediate {
+
+	if s == "" {
+		return nil
+	}
+
+	rxExternalDocs := regexp.MustCompile(`@(?i:externalDocs)\s+(\S+)(?:[ \t]+"?([^"\n]+)"?)?`)
+
+	if !rxExternalDocs.MatchString(s) {
+		return nil
+	}
+
+	matches := rxExternalDocs.FindStringSubmatch(s)
+
+	return &ExternalDocsIntermediate{
+		Url:         matches[1],
+		Description: strings.TrimSpace(matches[2]),
+	}
+}
+
+// Types may reference a package-level variable to use as their example with
+// either of the following:
+//
+//	@example ExampleVillage
+//	OpenAPI Example: types.ExampleVillage
+func parseExampleAnnotation(s string) string {
+
+	if s == "" {
+		return ""
+	}
+
+	rxExample := regexp.MustCompile(`(?:@(?i:example)|(?i:openapi example:))\s+([\w.]+)`)
+
+	if !rxExample.MatchString(s) {
+		return ""
+	}
+
+	matches := rxExample.FindStringSubmatch(s)
+
+	return matches[1]
+}
+
+// A type that marshals itself may describe its JSON with the following
+// annotation, where the format is optional:
+//
+//	@schema string uuid
+//
+// The type 'go' describes the Go type itself, ignoring its marshalers.
+func parseSchemaAnnotation(s string) (string, string) {
+
+	if s == "" {
+		return "", ""
+	}
+
+	rxSchema := regexp.MustCompile(`@(?i:schema)[ \t]+(\w+)(?:[ \t]+([\w.-]+))?`)
+
+	if !rxSchema.MatchString(s) {
+		return "", ""
+	}
+
+	matches := rxSchema.FindStringSubmatch(s)
+
+	return matches[1], matches[2]
+}
+
+// An interface type may be described as the union of its implementations,
+// which are told apart by the value of a discriminator property:
+//
+//	@discriminator kind
+//	@implementation created VillageCreated
+//	@implementation deleted VillageDeleted
+//
+// The value of an implementation is optional, and is the name of its
+// definition by default.
+func parseDiscriminatorAnnotation(s string) string {
+
+	if s == "" {
+		return ""
+	}
+
+	rxDiscriminator := regexp.MustCompile(`@(?i:discriminator)[ \t]+(\S+)`)
+
+	if !rxDiscriminator.MatchString(s) {
+		return ""
+	}
+
+	matches := rxDiscriminator.FindStringSubmatch(s)
+
+	return matches[1]
+}
+
+func parseImplementationAnnotations(s string) []ImplementationIntermediate {
+
+	implementations := make([]ImplementationIntermediate, 0)
+
+	rxImplementation := regexp.MustCompile(`@(?i:implementation)[ \t]+(\S+)(?:[ \t]+(\S+))?`)
+
+	for _, matches := range rxImplementation.FindAllStringSubmatch(s, -1) {
+		implementation := ImplementationIntermediate{Type: matches[1]}
+		if matches[2] != "" {
+			implementation.Value = matches[1]
+			implementation.Type = matches[2]
+		}
+
+		implementations = append(implementations, implementation)
+	}
+
+	return implementations
+}
+
+// A type implementing json.Marshaler or encoding.TextMarshaler doesn't look
+// like its Go declaration in JSON. Most such types marshal to strings (e.g.
+// UUIDs, decimals, and timestamps), so that's assumed, unless the type says
+// otherwise with '@schema'. Either receiver counts, as encoding/json uses the
+// methods of pointer receivers whenever the value is addressable.
+//
+// This is applied again once the methods promoted from embedded types are
+// known; see promoteMethods.
+func applyMarshalers(definition *DefinitionIntermediate) {
+
+	if definition.SchemaType == "go" {
+		definition.SchemaType = ""
+		definition.SchemaFormat = ""
+		definition.IgnoreMarshalers = true
+	}
+
+	if definition.IgnoreMarshalers {
+		return
+	}
+
+	if definition.SchemaType == "" {
+		if !definition.HasMethod("MarshalJSON", true) && !definition.HasMethod("MarshalText", true) {
+			return
+		}
+
+		definition.SchemaType = "string"
+	}
+
+	switch definition.SchemaType {
+	case "string", "integer", "number", "boolean", "object":
+	default:
+		log.Printf("WARNING: Unrecognized schema type of '%s': %s", definition.Name, definition.SchemaType)
+		definition.SchemaType = "string"
+	}
+
+	// The Go declaration no longer matters.
+	definition.Members = make(map[string]SchemerDefiner)
+	definition.EmbeddedTypes = make([]string, 0)
+}
+
 type OpenApiControls struct {
 	Ignore     bool
 	Deprecated bool
+	Extensions spec.Extensions
 }
 
+// Fields and types may be deprecated with either the '@deprecated' annotation or
+// a standard godoc paragraph beginning with 'Deprecated:'.
 func parseOpenApiControls(s string) OpenApiControls {
 
-	var controls OpenApiControls
+	var controls OpenApiControls = OpenApiControls{
+		Extensions: make(spec.Extensions),
+	}
 
 	rxIgnore := regexp.MustCompile(`@(?i:ignore)`)
-	rxDeprecated := regexp.MustCompile(`@(?i:deprecated)`)
+	rxDeprecated := regexp.MustCompile(`@(?i:deprecated)|(?:\A|\n[ \t]*\n)Deprecated:`)
+	rxExtensionAnnotation := regexp.MustCompile(`@(?i:extension)\s+(x-[\w.-]+)(?:[ \t]+([^\n]*))?`)
 
 	if rxIgnore.MatchString(s) {
 		controls.Ignore = true
@@ -378,5 +691,9 @@ func parseOpenApiControls(s string) OpenApiControls {
 		controls.Deprecated = true
 	}
 
+	for _, matches := range rxExtensionAnnotation.FindAllStringSubmatch(s, -1) {
+		controls.Extensions.Add(matches[1], parseExtensionValue(matches[2]))
+	}
+
 	return controls
 }
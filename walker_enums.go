@@ -1,18 +1,23 @@
 package main
 
 import (
-	"fmt"
 	"github.com/jackmanlabs/bucket/jlog"
 	"github.com/jackmanlabs/errors"
 	"go/ast"
 	"go/build"
+	"go/constant"
 	"go/parser"
 	"go/token"
 	"log"
+	"sort"
 	"strings"
 )
 
-func findEnumValues(referringPackage, typeName string) ([]interface{}, error) {
+// The values of an enum type are the constants of the type, wherever they're
+// declared: in any file of the type's package, or in any of the scanned
+// packages importing it. They're evaluated as the compiler would, so iota,
+// implicit repetition, arithmetic and references to other constants work.
+func findEnumValues(referringPackage, typeName string) ([]EnumIntermediate, error) {
 
 	pkgInfo := pkgInfos[referringPackage]
 	typeName = strings.TrimPrefix(typeName, "*")
@@ -29,10 +34,7 @@ func findEnumValues(referringPackage, typeName string) ([]interface{}, error) {
 		jlog.Log(importPaths)
 	}
 
-	if strings.Contains(typeName, ".") {
-		index := strings.Index(typeName, ".") + 1
-		typeName = typeName[index:]
-	}
+	_, typeName = splitTypeName(typeName)
 
 	for _, importPath := range importPaths {
 
@@ -40,43 +42,462 @@ func findEnumValues(referringPackage, typeName string) ([]interface{}, error) {
 			log.Print("Import path is blank!")
 		}
 
-		bpkg, err := build.Import(importPath, srcPath, 0)
+		cpkg, err := loadConstantPackage(importPath)
 		if err != nil {
 			return nil, errors.Stack(err)
 		}
 
-		fset := token.NewFileSet()
-		pkgs, err := parser.ParseDir(fset, bpkg.Dir, nil, parser.AllErrors)
-		if err != nil {
-			return nil, errors.Stack(err)
+		enumType := importPath + "." + typeName
+		if _, ok := cpkg.Types[typeName]; !ok {
+			continue
+		}
+
+		// The type's own package comes first.
+		declaringPaths := []string{importPath}
+		for path_, pkgInfo_ := range pkgInfos {
+			if _, ok := pkgInfo_.Imports[importPath]; ok && path_ != importPath {
+				declaringPaths = append(declaringPaths, path_)
+			}
 		}
+		sort.Strings(declaringPaths[1:])
 
-		for _, pkg := range pkgs {
-			enumVisitor := &EnumVisitor{
-				Fset:     fset,
-				TypeName: typeName,
-				Values:   make([]interface{}, 0),
+		var (
+			values     []EnumIntermediate   = make([]EnumIntermediate, 0)
+			seen       map[interface{}]bool = make(map[interface{}]bool)
+			underlying string               = underlyingType(enumType)
+		)
+
+		for _, declaringPath := range declaringPaths {
+			declaring, err := loadConstantPackage(declaringPath)
+			if err != nil {
+				return nil, errors.Stack(err)
 			}
 
-			ast.Walk(enumVisitor, pkg)
+			for _, decl := range declaring.Constants {
+				if decl.Name == "_" {
+					continue
+				}
+
+				value, typ, err := declaring.Value(decl.Name)
+				if err != nil {
+					// Every enum type looks at the same constants.
+					if !decl.reported {
+						log.Printf("WARNING: Failed to evaluate constant '%s' of package '%s': %s", decl.Name, declaringPath, err)
+						decl.reported = true
+					}
+					continue
+				}
 
-			return enumVisitor.Values, nil
+				if typ != enumType {
+					continue
+				}
+
+				v, ok := constantValue(value, underlying)
+				if !ok {
+					log.Printf("WARNING: Constant '%s' of enum type '%s' can't be described in JSON.", decl.Name, typeName)
+					continue
+				}
+
+				// Aliases of values, e.g. 'Default = Red', aren't values of their own.
+				if seen[v] {
+					continue
+				}
+				seen[v] = true
+
+				values = append(values, EnumIntermediate{
+					Name:        decl.Name,
+					Value:       v,
+					Description: decl.Doc,
+				})
+			}
 		}
+
+		return values, nil
 	}
 
 	return nil, nil
 }
 
-type EnumVisitor struct {
-	Fset     *token.FileSet
-	TypeName string
+// This converts an evaluated constant to the JSON value encoding/json produces
+// for a value of the underlying type.
+func constantValue(value constant.Value, underlying string) (interface{}, bool) {
+
+	switch {
+	case underlying == "string":
+		if value.Kind() != constant.String {
+			return nil, false
+		}
+		return constant.StringVal(value), true
+	case underlying == "bool":
+		if value.Kind() != constant.Bool {
+			return nil, false
+		}
+		return constant.BoolVal(value), true
+	case isIntegerKind(underlying):
+		value = constant.ToInt(value)
+		if value.Kind() != constant.Int {
+			return nil, false
+		}
+		if i, exact := constant.Int64Val(value); exact {
+			return i, true
+		}
+		if u, exact := constant.Uint64Val(value); exact {
+			return u, true
+		}
+		return nil, false
+	case underlying == "float32" || underlying == "float64":
+		value = constant.ToFloat(value)
+		if value.Kind() != constant.Float {
+			return nil, false
+		}
+		f, _ := constant.Float64Val(value)
+		return f, true
+	}
+
+	return nil, false
+}
+
+// A constant as declared, before it's evaluated. An implicitly repeated
+// declaration has the type and expression of the last explicit one.
+type ConstantDecl struct {
+	Name string
+	Type ast.Expr // nil for untyped constants.
+	Expr ast.Expr
+	Iota int
+	Doc  string // The documentation of the constant, on one line.
+
+	reported bool // Whether its evaluation failed and was reported.
+}
+
+// The constants and named types of a package, with the values of the
+// constants evaluated on demand. Types are qualified by import path, e.g.
+// 'example.com/app/types.Kind'; untyped constants have no type.
+type ConstantPackage struct {
+	ImportPath string
+	Constants  []*ConstantDecl          // In order of declaration.
+	Types      map[string]ast.Expr      // map[name]type
+	decls      map[string]*ConstantDecl // map[name]decl
+	values     map[string]constant.Value
+	types      map[string]string
+	errs       map[string]error
+	evaluating map[string]bool // Constants can't refer to themselves.
+}
+
+var constantPackages map[string]*ConstantPackage = make(map[string]*ConstantPackage)
+
+func loadConstantPackage(importPath string) (*ConstantPackage, error) {
+
+	if cpkg, ok := constantPackages[importPath]; ok {
+		return cpkg, nil
+	}
+
+	cpkg := &ConstantPackage{
+		ImportPath: importPath,
+		Constants:  make([]*ConstantDecl, 0),
+		Types:      make(map[string]ast.Expr),
+		decls:      make(map[string]*ConstantDecl),
+		values:     make(map[string]constant.Value),
+		types:      make(map[string]string),
+		errs:       make(map[string]error),
+		evaluating: make(map[string]bool),
+	}
+
+	bpkg, err := build.Import(importPath, srcPath, 0)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, bpkg.Dir, notTest, parser.AllErrors|parser.ParseComments)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	constantPackages[importPath] = cpkg
+
+	pkg, ok := pkgs[bpkg.Name]
+	if !ok {
+		return cpkg, nil
+	}
+
+	// Map iteration isn't ordered, but the values of an enum should be.
+	fileNames := make([]string, 0, len(pkg.Files))
+	for fileName := range pkg.Files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	for _, fileName := range fileNames {
+		constantVisitor := &ConstantVisitor{
+			Fset:    fset,
+			Package: cpkg,
+		}
+
+		ast.Walk(constantVisitor, pkg.Files[fileName])
+	}
+
+	return cpkg, nil
+}
+
+// This evaluates the named constant of the package, returning its value and
+// its type.
+func (this *ConstantPackage) Value(name string) (constant.Value, string, error) {
+
+	if value, ok := this.values[name]; ok {
+		return value, this.types[name], nil
+	}
+
+	if err, ok := this.errs[name]; ok {
+		return nil, "", err
+	}
+
+	decl, ok := this.decls[name]
+	if !ok {
+		return nil, "", errors.Newf("Constant not found: %s.%s", this.ImportPath, name)
+	}
+
+	if this.evaluating[name] {
+		return nil, "", errors.Newf("Constant refers to itself: %s.%s", this.ImportPath, name)
+	}
+	this.evaluating[name] = true
+	defer delete(this.evaluating, name)
+
+	if decl.Expr == nil {
+		return nil, "", errors.Newf("Constant has no value: %s.%s", this.ImportPath, name)
+	}
+
+	value, typ, err := this.eval(decl.Expr, decl.Iota)
+	if err != nil {
+		this.errs[name] = err
+		return nil, "", err
+	}
+
+	// A typed declaration converts its value.
+	if decl.Type != nil {
+		typ = this.qualifyType(decl.Type)
+		value = convertConstant(value, underlyingType(typ))
+	}
+
+	this.values[name] = value
+	this.types[name] = typ
+
+	return value, typ, nil
+}
+
+func (this *ConstantPackage) eval(expr ast.Expr, iota int) (constant.Value, string, error) {
+
+	switch t := expr.(type) {
+	case *ast.BasicLit:
+		return constant.MakeFromLiteral(t.Value, t.Kind, 0), "", nil
+	case *ast.ParenExpr:
+		return this.eval(t.X, iota)
+	case *ast.Ident:
+		switch t.Name {
+		case "iota":
+			return constant.MakeInt64(int64(iota)), "", nil
+		case "true", "false":
+			return constant.MakeBool(t.Name == "true"), "", nil
+		}
+		return this.Value(t.Name)
+	case *ast.SelectorExpr:
+		alias, ok := t.X.(*ast.Ident)
+		if !ok {
+			return nil, "", errors.Newf("Unsupported constant expression: %s", resolveTypeExpression(t))
+		}
+
+		importPath, ok := this.importPath(alias.Name)
+		if !ok {
+			return nil, "", errors.Newf("Package not found: %s", alias.Name)
+		}
+
+		cpkg, err := loadConstantPackage(importPath)
+		if err != nil {
+			return nil, "", errors.Stack(err)
+		}
+
+		return cpkg.Value(t.Sel.Name)
+	case *ast.UnaryExpr:
+		x, typ, err := this.eval(t.X, iota)
+		if err != nil {
+			return nil, "", errors.Stack(err)
+		}
+
+		// The complement of an unsigned value is limited by its size.
+		return constant.UnaryOp(t.Op, x, unsignedPrecision(underlyingType(typ))), typ, nil
+	case *ast.BinaryExpr:
+		x, xType, err := this.eval(t.X, iota)
+		if err != nil {
+			return nil, "", errors.Stack(err)
+		}
+
+		y, yType, err := this.eval(t.Y, iota)
+		if err != nil {
+			return nil, "", errors.Stack(err)
+		}
+
+		typ := xType
+		if typ == "" {
+			typ = yType
+		}
+
+		switch t.Op {
+		case token.SHL, token.SHR:
+			s, ok := constant.Uint64Val(constant.ToInt(y))
+			if !ok {
+				return nil, "", errors.Newf("Invalid shift count: %s", y)
+			}
+			return constant.Shift(constant.ToInt(x), t.Op, uint(s)), xType, nil
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return constant.MakeBool(constant.Compare(x, t.Op, y)), "", nil
+		case token.LAND, token.LOR:
+			if x.Kind() != constant.Bool || y.Kind() != constant.Bool {
+				return nil, "", errors.Newf("Invalid operands of %s: %s, %s", t.Op, x, y)
+			}
+			return constant.BinaryOp(x, t.Op, y), typ, nil
+		}
+
+		if typ != "" {
+			x = convertConstant(x, underlyingType(typ))
+			y = convertConstant(y, underlyingType(typ))
+		}
+
+		op := t.Op
+		if op == token.QUO && x.Kind() == constant.Int && y.Kind() == constant.Int {
+			op = token.QUO_ASSIGN // Integer division.
+		}
+
+		if (op == token.QUO || op == token.QUO_ASSIGN || op == token.REM) && constant.Sign(y) == 0 {
+			return nil, "", errors.New("Division by zero.")
+		}
+
+		return constant.BinaryOp(x, op, y), typ, nil
+	case *ast.CallExpr:
+		if len(t.Args) != 1 {
+			return nil, "", errors.Newf("Unsupported constant expression: %s", resolveTypeExpression(t.Fun))
+		}
+
+		x, _, err := this.eval(t.Args[0], iota)
+		if err != nil {
+			return nil, "", errors.Stack(err)
+		}
+
+		if ident, ok := t.Fun.(*ast.Ident); ok && ident.Name == "len" && x.Kind() == constant.String {
+			return constant.MakeInt64(int64(len(constant.StringVal(x)))), "", nil
+		}
+
+		// Anything else is a conversion, e.g. 'Kind(2)'.
+		typ := this.qualifyType(t.Fun)
+		underlying := underlyingType(typ)
+		if underlying == "string" && x.Kind() == constant.Int {
+			if r, ok := constant.Int64Val(x); ok {
+				return constant.MakeString(string(rune(r))), typ, nil
+			}
+		}
+
+		return convertConstant(x, underlying), typ, nil
+	}
+
+	return nil, "", errors.Newf("Unsupported constant expression: %T", expr)
+}
+
+// This qualifies a named type by its import path. Predeclared types, e.g.
+// 'int', are left as they are.
+func (this *ConstantPackage) qualifyType(expr ast.Expr) string {
+
+	goType := resolveTypeExpression(expr)
+	if isPrimitive, _, _ := IsPrimitive(goType); isPrimitive {
+		return goType
+	}
+
+	qualifier, name := splitTypeName(goType)
+	if qualifier == "" {
+		return this.ImportPath + "." + name
+	}
+
+	if importPath, ok := this.importPath(qualifier); ok {
+		return importPath + "." + name
+	}
+
+	return goType
+}
+
+func (this *ConstantPackage) importPath(alias string) (string, bool) {
+
+	importPaths := possibleImportPaths(pkgInfos[this.ImportPath], alias+".X")
+	if len(importPaths) != 1 {
+		return "", false
+	}
+
+	return importPaths[0], true
+}
+
+// This returns the predeclared type underlying a qualified type, e.g. 'int' for
+// 'example.com/app/types.Kind'.
+func underlyingType(typ string) string {
 
-	// I would really like to store the names for the values, but the
-	// JSON/OpenAPI spec only wants the values.
-	Values []interface{}
+	for i := 0; i < 10; i++ {
+		if isPrimitive, _, _ := IsPrimitive(typ); isPrimitive || typ == "" {
+			return typ
+		}
+
+		importPath, name := splitTypeName(typ)
+		cpkg, err := loadConstantPackage(importPath)
+		if err != nil {
+			return ""
+		}
+
+		expr, ok := cpkg.Types[name]
+		if !ok {
+			return ""
+		}
+
+		typ = cpkg.qualifyType(expr)
+	}
+
+	return ""
 }
 
-func (this *EnumVisitor) Visit(node ast.Node) (w ast.Visitor) {
+// A typed constant has the representation of its type, e.g. 'const x float64
+// = 1' is a float.
+func convertConstant(value constant.Value, underlying string) constant.Value {
+
+	switch {
+	case isIntegerKind(underlying):
+		if v := constant.ToInt(value); v.Kind() == constant.Int {
+			return v
+		}
+	case underlying == "float32" || underlying == "float64":
+		if v := constant.ToFloat(value); v.Kind() == constant.Float {
+			return v
+		}
+	}
+
+	return value
+}
+
+func unsignedPrecision(underlying string) uint {
+
+	switch underlying {
+	case "uint8", "byte":
+		return 8
+	case "uint16":
+		return 16
+	case "uint32":
+		return 32
+	case "uint", "uint64", "uintptr":
+		return 64
+	}
+
+	return 0
+}
+
+// This collects the constant and type declarations of a file.
+type ConstantVisitor struct {
+	Fset    *token.FileSet
+	Package *ConstantPackage
+}
+
+func (this *ConstantVisitor) Visit(node ast.Node) (w ast.Visitor) {
 
 	if this.Fset == nil {
 		log.Println("fset is nil.")
@@ -86,109 +507,74 @@ func (this *EnumVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	switch t := node.(type) {
 
 	case *ast.GenDecl:
+		if t.Tok == token.TYPE {
+			for _, spec := range t.Specs {
+				if typeSpec, ok := spec.(*ast.TypeSpec); ok {
+					this.Package.Types[typeSpec.Name.Name] = typeSpec.Type
+				}
+			}
+			return nil
+		}
 
-		//ast.Fprint(os.Stderr, this.Fset, t, ast.NotNilFilter)
+		if t.Tok != token.CONST {
+			return nil
+		}
 
 		// I've seen some folks that have many different kinds of constants in a
 		// single const() declaration. Therefore, we need to assume that any of
 		// the declarations could pertain to our type.
 
 		var (
-			nam string = ""
-			typ string = ""
-			val interface{}
-			iot int = 0
+			typ    ast.Expr
+			values []ast.Expr
 		)
 
-		for _, spec := range t.Specs {
+		for iota, spec := range t.Specs {
 			valSpec, ok := spec.(*ast.ValueSpec)
 			if !ok {
-				//log.Printf("unexpected type %T\n", t) // %T prints whatever type t has
 				continue
 			}
 
-			if valSpec.Type != nil {
-				typ = resolveTypeExpression(valSpec.Type)
+			// A declaration without values repeats the last one.
+			if len(valSpec.Values) > 0 {
+				typ = valSpec.Type
+				values = valSpec.Values
 			}
 
-			if typ != this.TypeName {
-				continue
+			// The documentation of an ungrouped constant belongs to the
+			// declaration, and a trailing comment documents it as well.
+			doc := valSpec.Doc.Text()
+			if doc == "" && !t.Lparen.IsValid() {
+				doc = t.Doc.Text()
+			}
+			if doc == "" {
+				doc = valSpec.Comment.Text()
 			}
 
-			if len(valSpec.Values) == 0 {
-				// Assume iota is in play.
-				iot++
-				val = iot
-			} else if len(valSpec.Values) > 1 {
-				log.Print("WARNING: A possible constant declaration was found, but has more than one value: " + typ)
-				continue
-			} else {
-				val = resolveValueExpression(valSpec.Values[0])
-				if val == "iota" {
-					// reset iota
-					val = 0
-					iot = 0
+			for i, name := range valSpec.Names {
+				decl := &ConstantDecl{
+					Name: name.Name,
+					Type: typ,
+					Iota: iota,
+					Doc:  strings.Join(strings.Fields(doc), " "),
 				}
-			}
 
-			if len(valSpec.Names) != 1 {
-				log.Print("WARNING: A possible constant declaration was found, but has more than one name: " + typ)
-				continue
-			} else {
-				nam = valSpec.Names[0].Name
-			}
+				if i < len(values) {
+					decl.Expr = values[i]
+				}
 
-			if nam == "_" {
-				continue
+				this.Package.Constants = append(this.Package.Constants, decl)
+				if name.Name != "_" {
+					this.Package.decls[name.Name] = decl
+				}
 			}
+		}
 
-			this.Values = append(this.Values, val)
-
-			//log.Printf("var %s\t%s = %v", nam, typ, val)
-		}
-
-		//case *ast.ValueSpec:
-	//
-	//	valueType := resolveTypeExpression(t.Type)
-	//	if valueType != this.TypeName {
-	//		return nil
-	//	}
-	//
-	//	//ast.Fprint(os.Stderr, this.Fset, t, ast.NotNilFilter)
-	//
-	//	// Assume we have one name and one value.
-	//	if len(t.Names) != 1 || len(t.Values) != 1 {
-	//		log.Print("WARNING: A possible constant declaration was found, but has more than one name or value: " + valueType)
-	//		return nil
-	//	}
-	//
-	//	valueValue := resolveValueExpression(t.Values[0])
-	//
-	//	this.Values = append(this.Values, valueValue)
-
+		return nil
 	case *ast.FuncDecl:
 		// Ignore function declarations.
 		return nil
-	case *ast.ImportSpec:
-		// Ignore import declarations.
-		return nil
-	case nil:
-		//default:
-		//	log.Printf("unexpected type %T\n", t) // %T prints whatever type t has
 	}
 
 	return this
 }
-
-func resolveValueExpression(expr ast.Expr) interface{} {
-	switch t := expr.(type) {
-	case *ast.BasicLit:
-		return t.Value
-	case *ast.UnaryExpr:
-		return t.Op.String() + fmt.Sprint(resolveValueExpression(t.X))
-	case *ast.Ident:
-		return t.Name
-	default:
-		return fmt.Sprintf("Unknown<%T>", t)
-	}
-}
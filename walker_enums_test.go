@@ -0,0 +1,201 @@
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+const testConstantsTypes = `package types
+
+import other "example.com/app/more"
+
+type Kind int
+type Mask uint8
+type Name string
+type Ratio float64
+type Alias Kind
+
+const (
+	Zero Kind = iota
+	One
+	Two
+	_
+	Four
+)
+
+const (
+	Read Mask = 1 << iota
+	Write
+	Exec
+	All  = Read | Write | Exec
+	None = ^All
+)
+
+const (
+	A, B = iota * 10, iota * 20
+	C, D
+)
+
+const Hello Name = "hel" + "lo"
+const Length = len(Hello)
+const Half Ratio = 1 / 2.0
+const Quotient = 7 / 2
+const Remainder = 7 % 2
+const Converted = Kind(7)
+const Aliased Alias = 3
+const Letter = Name(65)
+const Big = 1 << 62
+const Greater = Two > One
+const Purple = other.Purple + 1
+
+const Loop = Loop2
+const Loop2 = Loop
+const Undefined = Nowhere
+const Zeroed = 1 / 0
+`
+
+const testConstantsMore = `package more
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Purple
+)
+`
+
+// This loads the constants of the source as those of the package, as if it
+// were imported.
+func loadTestConstantPackage(t *testing.T, importPath, src string) {
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, importPath+".go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cpkg := &ConstantPackage{
+		ImportPath: importPath,
+		Constants:  make([]*ConstantDecl, 0),
+		Types:      make(map[string]ast.Expr),
+		decls:      make(map[string]*ConstantDecl),
+		values:     make(map[string]constant.Value),
+		types:      make(map[string]string),
+		errs:       make(map[string]error),
+		evaluating: make(map[string]bool),
+	}
+
+	ast.Walk(&ConstantVisitor{Fset: fset, Package: cpkg}, file)
+
+	constantPackages[importPath] = cpkg
+}
+
+func TestConstantPackageValue(t *testing.T) {
+
+	defer func(saved map[string]PackageInfo) { pkgInfos = saved }(pkgInfos)
+	defer func(saved map[string]*ConstantPackage) { constantPackages = saved }(constantPackages)
+
+	pkgInfos = map[string]PackageInfo{
+		"example.com/app/types": {
+			ImportPath:  "example.com/app/types",
+			PackageName: "types",
+			Imports:     map[string][]string{"example.com/app/more": {"other"}},
+		},
+		"example.com/app/more": {
+			ImportPath:  "example.com/app/more",
+			PackageName: "more",
+			Imports:     map[string][]string{},
+		},
+	}
+	constantPackages = make(map[string]*ConstantPackage)
+
+	loadTestConstantPackage(t, "example.com/app/types", testConstantsTypes)
+	loadTestConstantPackage(t, "example.com/app/more", testConstantsMore)
+
+	cpkg := constantPackages["example.com/app/types"]
+
+	tests := []struct {
+		name    string
+		value   string // As formatted by constant.Value.String.
+		typ     string
+		invalid bool
+	}{
+		{name: "Zero", value: "0", typ: "example.com/app/types.Kind"},
+		{name: "Two", value: "2", typ: "example.com/app/types.Kind"},
+		{name: "Four", value: "4", typ: "example.com/app/types.Kind"},
+		{name: "Exec", value: "4", typ: "example.com/app/types.Mask"},
+		{name: "All", value: "7", typ: "example.com/app/types.Mask"},
+		{name: "None", value: "248", typ: "example.com/app/types.Mask"},
+		{name: "A", value: "0", typ: ""},
+		{name: "B", value: "0", typ: ""},
+		{name: "C", value: "10", typ: ""},
+		{name: "D", value: "20", typ: ""},
+		{name: "Hello", value: `"hello"`, typ: "example.com/app/types.Name"},
+		{name: "Length", value: "5", typ: ""},
+		{name: "Half", value: "0.5", typ: "example.com/app/types.Ratio"},
+		{name: "Quotient", value: "3", typ: ""},
+		{name: "Remainder", value: "1", typ: ""},
+		{name: "Converted", value: "7", typ: "example.com/app/types.Kind"},
+		{name: "Aliased", value: "3", typ: "example.com/app/types.Alias"},
+		{name: "Letter", value: `"A"`, typ: "example.com/app/types.Name"},
+		{name: "Big", value: "4611686018427387904", typ: ""},
+		{name: "Greater", value: "true", typ: ""},
+		{name: "Purple", value: "3", typ: "example.com/app/more.Color"},
+		{name: "Loop", invalid: true},
+		{name: "Undefined", invalid: true},
+		{name: "Zeroed", invalid: true},
+		{name: "Missing", invalid: true},
+	}
+
+	for _, test := range tests {
+		value, typ, err := cpkg.Value(test.name)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("Value(%q) = %s, %q; want an error", test.name, value, typ)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Value(%q) failed: %s", test.name, err)
+			continue
+		}
+
+		if value.String() != test.value || typ != test.typ {
+			t.Errorf("Value(%q) = %s, %q; want %s, %q", test.name, value, typ, test.value, test.typ)
+		}
+	}
+}
+
+func TestConstantValue(t *testing.T) {
+
+	tests := []struct {
+		value      constant.Value
+		underlying string
+		want       interface{}
+		ok         bool
+	}{
+		{constant.MakeInt64(-3), "int", int64(-3), true},
+		{constant.MakeUint64(1 << 63), "uint64", uint64(1 << 63), true},
+		{constant.MakeFloat64(2), "uint8", int64(2), true},
+		{constant.MakeFloat64(0.5), "int", nil, false},
+		{constant.MakeInt64(2), "float64", float64(2), true},
+		{constant.MakeFloat64(0.25), "float32", 0.25, true},
+		{constant.MakeString("red"), "string", "red", true},
+		{constant.MakeInt64(1), "string", nil, false},
+		{constant.MakeBool(true), "bool", true, true},
+		{constant.MakeInt64(1), "complex128", nil, false},
+	}
+
+	for _, test := range tests {
+		got, ok := constantValue(test.value, test.underlying)
+		if ok != test.ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("constantValue(%s, %q) = %v, %t; want %v, %t", test.value, test.underlying, got, ok, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jackmanlabs/errors"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Examples may reference package-level variables instead of giving JSON, e.g.
+// 'types.ExampleVillage'. The variable must be initialized with a literal,
+// which is converted to JSON statically.
+var rxExampleReference *regexp.Regexp = regexp.MustCompile(`^[A-Za-z_]\w*(\.[A-Za-z_]\w*)?$`)
+
+// This resolves the variable references of all operation examples, relative to
+// the package in which the operation was found.
+func resolveOperationExamples(operationIntermediates []OperationIntermediate) {
+	for _, operationIntermediate := range operationIntermediates {
+		for i, example := range operationIntermediate.Examples {
+			if example.Reference == "" {
+				continue
+			}
+
+			value, err := findExampleValue(operationIntermediate.PackagePath, example.Reference)
+			if err != nil {
+				log.Printf("WARNING: Failed to resolve example '%s' of %s %s: %s", example.Reference, operationIntermediate.Method, operationIntermediate.Path, err)
+				continue
+			}
+
+			operationIntermediate.Examples[i].Value = value
+		}
+	}
+}
+
+// This resolves the example variable references of all definitions, relative
+// to the package in which the type was found.
+func resolveDefinitionExamples(defStore DefinitionStore) {
+	for _, def := range defStore {
+		if def.ExampleRef == "" {
+			continue
+		}
+
+		value, err := findExampleValue(def.PackagePath, def.ExampleRef)
+		if err != nil {
+			log.Printf("WARNING: Failed to resolve example '%s' of type '%s': %s", def.ExampleRef, def.Name, err)
+			continue
+		}
+
+		def.Example = value
+	}
+}
+
+func findExampleValue(referringPackage, reference string) (json.RawMessage, error) {
+
+	pkgInfo := pkgInfos[referringPackage]
+	importPaths := possibleImportPaths(pkgInfo, reference)
+
+	name := reference
+	if strings.Contains(name, ".") {
+		index := strings.Index(name, ".") + 1
+		name = name[index:]
+	}
+
+	var failures []string
+	for _, importPath := range importPaths {
+
+		// Another candidate may still declare the variable.
+		bpkg, err := build.Import(importPath, srcPath, 0)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+
+		fset := token.NewFileSet()
+		pkgs, err := parser.ParseDir(fset, bpkg.Dir, notTest, parser.AllErrors)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+
+		for _, pkg := range pkgs {
+			exampleVisitor := &ExampleVisitor{
+				Fset:    fset,
+				VarName: name,
+			}
+
+			ast.Walk(exampleVisitor, pkg)
+
+			if exampleVisitor.Value == nil {
+				continue
+			}
+
+			converter := &ExampleConverter{
+				Fset:        fset,
+				PackagePath: importPath,
+				Definitions: make(map[string]*DefinitionIntermediate),
+			}
+
+			value := converter.Convert(exampleVisitor.Value, "")
+
+			b, err := json.Marshal(value)
+			if err != nil {
+				return nil, errors.Stack(err)
+			}
+
+			return json.RawMessage(b), nil
+		}
+	}
+
+	if len(failures) > 0 {
+		return nil, errors.Newf("Variable not found: %s (%s)", reference, strings.Join(failures, "; "))
+	}
+
+	return nil, errors.New("Variable not found: " + reference)
+}
+
+// This finds the expression used to initialize a package-level variable or
+// constant.
+type ExampleVisitor struct {
+	Fset    *token.FileSet
+	VarName string
+	Value   ast.Expr
+}
+
+func (this *ExampleVisitor) Visit(node ast.Node) (w ast.Visitor) {
+
+	if this.Fset == nil {
+		log.Println("fset is nil.")
+		return nil
+	}
+
+	switch t := node.(type) {
+
+	case *ast.ValueSpec:
+		for i, name := range t.Names {
+			if name.Name == this.VarName && i < len(t.Values) {
+				this.Value = t.Values[i]
+			}
+		}
+
+		return nil
+	case *ast.FuncDecl:
+		// Only package-level declarations are of interest.
+		return nil
+	case *ast.ImportSpec:
+		// Ignore import declarations.
+		return nil
+	}
+
+	return this
+}
+
+/*
+The ExampleConverter transforms a Go literal into a value that encodes to the
+same JSON as encoding/json would produce for it, using the field names of the
+definitions we generate.
+
+Only the fields given in the literal are included. Expressions that can't be
+evaluated statically (function calls, references to other variables, etc.) are
+reported and encoded as null.
+
+All type names are relative to the package in which the literal was declared.
+*/
+type ExampleConverter struct {
+	Fset        *token.FileSet
+	PackagePath string
+	Definitions map[string]*DefinitionIntermediate // map[goType]definition
+}
+
+// The Go type is only required for literals with elided types, e.g. the
+// elements of '[]Village{{Name: "x"}}'.
+func (this *ExampleConverter) Convert(expr ast.Expr, goType string) interface{} {
+
+	switch t := expr.(type) {
+
+	case *ast.ParenExpr:
+		return this.Convert(t.X, goType)
+
+	case *ast.BasicLit:
+		return this.convertBasicLit(t)
+
+	case *ast.UnaryExpr:
+		switch t.Op {
+		case token.AND:
+			return this.Convert(t.X, strings.TrimPrefix(goType, "*"))
+		case token.SUB:
+			switch v := this.Convert(t.X, goType).(type) {
+			case int64:
+				return -v
+			case float64:
+				return -v
+			}
+		case token.ADD:
+			return this.Convert(t.X, goType)
+		}
+
+	case *ast.Ident:
+		switch t.Name {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "nil":
+			return nil
+		}
+
+	case *ast.CallExpr:
+		// Type conversions, e.g. 'Kind(3)', are the only calls we can evaluate.
+		if len(t.Args) == 1 {
+			switch t.Fun.(type) {
+			case *ast.Ident, *ast.SelectorExpr:
+				typeName := resolveTypeExpression(t.Fun)
+				if isPrimitive, _, _ := IsPrimitive(typeName); isPrimitive || this.definition(typeName) != nil {
+					return this.Convert(t.Args[0], typeName)
+				}
+			}
+		}
+
+	case *ast.CompositeLit:
+		if t.Type != nil {
+			goType = resolveTypeExpression(t.Type)
+		}
+		goType = strings.TrimPrefix(goType, "*")
+
+		if isMap, _, v := IsMap(goType); isMap {
+			return this.convertMap(t, v)
+		} else if isSlice, v := IsSlice(goType); isSlice {
+			return this.convertSlice(t, v)
+		}
+
+		return this.convertStruct(t, goType)
+	}
+
+	log.Printf("WARNING: Example expression can't be evaluated statically and is encoded as null: %s", this.Fset.Position(expr.Pos()))
+
+	return nil
+}
+
+func (this *ExampleConverter) convertBasicLit(lit *ast.BasicLit) interface{} {
+
+	switch lit.Kind {
+	case token.INT:
+		v, err := strconv.ParseInt(lit.Value, 0, 64)
+		if err == nil {
+			return v
+		}
+	case token.FLOAT:
+		v, err := strconv.ParseFloat(lit.Value, 64)
+		if err == nil {
+			return v
+		}
+	case token.STRING:
+		v, err := strconv.Unquote(lit.Value)
+		if err == nil {
+			return v
+		}
+	case token.CHAR:
+		v, _, _, err := strconv.UnquoteChar(strings.Trim(lit.Value, "'"), '\'')
+		if err == nil {
+			return int64(v)
+		}
+	}
+
+	log.Printf("WARNING: Example literal can't be parsed and is encoded as null: %s", lit.Value)
+
+	return nil
+}
+
+func (this *ExampleConverter) convertSlice(lit *ast.CompositeLit, valueType string) interface{} {
+
+	out := make([]interface{}, 0, len(lit.Elts))
+
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			// Indexed elements are rare; we keep them in the order given.
+			elt = kv.Value
+		}
+
+		out = append(out, this.Convert(elt, valueType))
+	}
+
+	return out
+}
+
+func (this *ExampleConverter) convertMap(lit *ast.CompositeLit, valueType string) interface{} {
+
+	out := make(map[string]interface{})
+
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+
+		key := this.Convert(kv.Key, "")
+		out[fmt.Sprint(key)] = this.Convert(kv.Value, valueType)
+	}
+
+	return out
+}
+
+// The fields of a struct literal are named by the JSON names of the members of
+// the corresponding definition. The fields of embedded structs are promoted to
+// the parent object, as encoding/json does.
+func (this *ExampleConverter) convertStruct(lit *ast.CompositeLit, goType string) interface{} {
+
+	out := make(map[string]interface{})
+
+	def := this.definition(goType)
+	if def == nil {
+		log.Printf("WARNING: Example type '%s' could not be found: %s", goType, this.Fset.Position(lit.Pos()))
+		return nil
+	}
+
+	embedded := make(map[string]string) // map[fieldName]goType
+	for _, embeddedType := range def.EmbeddedTypes {
+		name := strings.TrimPrefix(embeddedType, "*")
+		if idx := strings.LastIndex(name, "."); idx > -1 {
+			name = name[idx+1:]
+		}
+		embedded[name] = embeddedType
+	}
+
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			log.Printf("WARNING: Example struct literals must use field names: %s", this.Fset.Position(elt.Pos()))
+			return out
+		}
+
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+
+		if _, ok := embedded[key.Name]; ok {
+			if promoted, ok := this.Convert(kv.Value, "").(map[string]interface{}); ok {
+				for k, v := range promoted {
+					// Fields of the parent shadow those of the embedded struct.
+					if _, exists := out[k]; !exists {
+						out[k] = v
+					}
+				}
+			}
+			continue
+		}
+
+		member, ok := def.Members[key.Name]
+		if !ok {
+			// The field is unexported or ignored.
+			continue
+		}
+
+		out[member.Schema().Title] = this.Convert(kv.Value, "")
+	}
+
+	return out
+}
+
+func (this *ExampleConverter) definition(goType string) *DefinitionIntermediate {
+
+	goType = strings.TrimPrefix(goType, "*")
+
+	if def, ok := this.Definitions[goType]; ok {
+		return def
+	}
+
+	if isPrimitive, _, _ := IsPrimitive(goType); isPrimitive {
+		return nil
+	}
+
+	def, err := findDefinition(this.PackagePath, goType)
+	if err != nil {
+		log.Print(errors.Stack(err))
+	}
+
+	this.Definitions[goType] = def
+
+	return def
+}